@@ -0,0 +1,239 @@
+package affected
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/expectedsh/gomon/pkg/config"
+	"github.com/expectedsh/gomon/pkg/imports"
+)
+
+var Command = &cobra.Command{
+	Use:   "affected",
+	Short: "Print the applications affected by the changes since a git reference",
+	Long: "Print the applications affected by the changes since a git reference. " +
+		"The exit code is 0 when at least one application is affected, 2 when none is.",
+	Example:      "gomon affected --since origin/main",
+	RunE:         run,
+	SilenceUsage: true,
+}
+
+// ErrNoneAffected is returned when no application is affected, gomon exits
+// with the code 2.
+var ErrNoneAffected = errors.New("no application is affected")
+
+var (
+	fSince  string
+	fFormat string
+)
+
+type affectedApp struct {
+	Name  string   `json:"name"`
+	Files []string `json:"files"`
+}
+
+type result struct {
+	Since string `json:"since"`
+	// All is true when a change affects every application, such as a change
+	// of a go.mod or of the config.
+	All  bool          `json:"all"`
+	Apps []affectedApp `json:"apps"`
+}
+
+func run(c *cobra.Command, _ []string) error {
+	if fFormat != "text" && fFormat != "json" {
+		return fmt.Errorf("unknown format %q, use text or json", fFormat)
+	}
+
+	cfg, err := config.LoadFromFlags(c.Flags())
+	if err != nil {
+		return err
+	}
+
+	files, err := changedFiles(fSince)
+	if err != nil {
+		return err
+	}
+
+	res := result{Since: fSince, Apps: []affectedApp{}}
+	for _, file := range files {
+		if affectsAll(cfg, file) {
+			res.All = true
+		}
+	}
+
+	for _, app := range cfg.Apps {
+		graph, err := imports.LoadApplication(app)
+		if err != nil {
+			return errors.Wrap(err, "unable to load the packages of "+app.Name)
+		}
+
+		moduleFiles, err := appModuleFiles(app, graph)
+		if err != nil {
+			return err
+		}
+
+		affected := affectedApp{Name: app.Name, Files: []string{}}
+		for _, file := range files {
+			if res.All || moduleFiles[file] || isInput(app, graph, file) {
+				affected.Files = append(affected.Files, file)
+			}
+		}
+
+		if len(affected.Files) > 0 {
+			res.Apps = append(res.Apps, affected)
+		}
+	}
+
+	if fFormat == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(res); err != nil {
+			return err
+		}
+	} else {
+		for _, app := range res.Apps {
+			fmt.Println(app.Name)
+		}
+	}
+
+	if len(res.Apps) == 0 {
+		return ErrNoneAffected
+	}
+
+	return nil
+}
+
+// changedFiles returns the files changed since the reference, relative to the
+// working directory as the paths of the config.
+func changedFiles(ref string) ([]string, error) {
+	root, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := git("diff", "--name-only", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, name := range strings.Split(out, "\n") {
+		if name == "" {
+			continue
+		}
+
+		file := filepath.Join(root, name)
+		if rel, err := filepath.Rel(wd, file); err == nil {
+			file = rel
+		}
+		files = append(files, file)
+	}
+
+	return files, nil
+}
+
+func git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "git %s: %s", strings.Join(args, " "), strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// affectsAll reports whether the file changes the config of every
+// application: the config files and the global env files.
+func affectsAll(cfg *config.Config, file string) bool {
+	for _, source := range append(append([]string{}, cfg.Sources...), cfg.Settings.EnvFile...) {
+		if filepath.Clean(source) == file {
+			return true
+		}
+	}
+
+	return false
+}
+
+// appModuleFiles returns the module files the application is built with, a
+// change of one of them can change all its dependencies.
+func appModuleFiles(app config.Application, graph *imports.Graph) (map[string]bool, error) {
+	files := map[string]bool{}
+	if app.IsCommand() {
+		return files, nil
+	}
+
+	moduleDir, err := app.ModuleDir()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range imports.ModuleFiles(moduleDir, graph) {
+		files[filepath.Clean(file)] = true
+	}
+
+	return files, nil
+}
+
+// isInput reports whether the file is an input of the application, with the
+// same rules as the watcher of gomon run. A go file that was removed is an
+// input when it was in a package of the application.
+func isInput(app config.Application, graph *imports.Graph, file string) bool {
+	if app.IsExcluded(file) {
+		return false
+	}
+
+	if _, ok := graph.Files[file]; ok {
+		return true
+	}
+
+	if _, ok := graph.EmbeddingPackage(file); ok {
+		return true
+	}
+
+	if _, err := os.Stat(file); os.IsNotExist(err) && strings.HasSuffix(file, ".go") {
+		if _, ok := graph.PackageInDir(filepath.Dir(file)); ok {
+			return true
+		}
+	}
+
+	for _, envFile := range app.EnvFile {
+		if filepath.Clean(envFile) == file {
+			return true
+		}
+	}
+
+	return app.MatchesWatchPatterns(file)
+}
+
+func init() {
+	Command.Flags().StringVar(
+		&fSince, "since",
+		"",
+		"the git reference to compare the working tree with")
+
+	Command.Flags().StringVarP(
+		&fFormat, "format",
+		"f",
+		"text",
+		"the output format: text or json")
+
+	_ = Command.MarkFlagRequired("since")
+}
@@ -0,0 +1,56 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	gomonconfig "github.com/expectedsh/gomon/pkg/config"
+)
+
+var Command = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the config files",
+}
+
+var printCommand = &cobra.Command{
+	Use:          "print",
+	Short:        "Print the config resolved from every config file and the profile",
+	Example:      "gomon --config .gomon.yaml --config .gomon.local.yaml config print",
+	RunE:         printConfig,
+	SilenceUsage: true,
+}
+
+func printConfig(c *cobra.Command, _ []string) error {
+	cfg, err := gomonconfig.LoadFromFlags(c.Flags())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("# resolved from %s", strings.Join(cfg.Files, ", "))
+	if cfg.Profile != "" {
+		fmt.Printf(" with the profile %s", cfg.Profile)
+	}
+	fmt.Println()
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(gomonconfig.File{
+		Settings:   cfg.Settings,
+		Apps:       cfg.Apps,
+		Generators: cfg.Generators,
+		Profiles:   cfg.Profiles,
+	}); err != nil {
+		return err
+	}
+
+	return encoder.Close()
+}
+
+func init() {
+	Command.AddCommand(printCommand)
+}
@@ -0,0 +1,149 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/expectedsh/gomon/pkg/config"
+	"github.com/expectedsh/gomon/pkg/imports"
+)
+
+var Command = &cobra.Command{
+	Use:          "graph",
+	Short:        "Print the local packages each application depends on",
+	Example:      "gomon graph --format dot | dot -Tsvg > graph.svg",
+	RunE:         run,
+	SilenceUsage: true,
+}
+
+var fFormat string
+
+type appGraph struct {
+	Name     string             `json:"name"`
+	Main     string             `json:"main"`
+	Packages []*imports.Package `json:"packages"`
+}
+
+func run(c *cobra.Command, _ []string) error {
+	cfg, err := config.LoadFromFlags(c.Flags())
+	if err != nil {
+		return err
+	}
+
+	var graphs []appGraph
+	for _, app := range cfg.Apps {
+		graph, err := imports.LoadApplication(app)
+		if err != nil {
+			return errors.Wrap(err, "unable to load the packages of "+app.Name)
+		}
+
+		packages := graph.SortedPackages()
+		for _, pkg := range packages {
+			pkg.Imports = localImports(graph, pkg)
+		}
+
+		graphs = append(graphs, appGraph{Name: app.Name, Main: graph.Main, Packages: packages})
+	}
+
+	switch fFormat {
+	case "text":
+		printText(graphs)
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(graphs)
+	case "dot":
+		printDot(graphs)
+	default:
+		return fmt.Errorf("unknown format %q, use text, json or dot", fFormat)
+	}
+
+	return nil
+}
+
+// localImports returns the imports of the package that are part of the graph,
+// the standard library and the external modules are not watched.
+func localImports(graph *imports.Graph, pkg *imports.Package) []string {
+	var local []string
+	for _, importPath := range pkg.Imports {
+		if _, ok := graph.Packages[importPath]; ok {
+			local = append(local, importPath)
+		}
+	}
+
+	return local
+}
+
+func printText(graphs []appGraph) {
+	for i, graph := range graphs {
+		if i > 0 {
+			fmt.Println()
+		}
+
+		if graph.Main == "" {
+			fmt.Printf("%s (command), no go package\n", graph.Name)
+			continue
+		}
+
+		fmt.Printf("%s (%s), %d package(s)\n", graph.Name, graph.Main, len(graph.Packages))
+		for _, pkg := range graph.Packages {
+			fmt.Printf("  %s (%s, %d file(s))\n", pkg.ImportPath, pkg.Dir, len(pkg.Files))
+			if len(pkg.Imports) > 0 {
+				fmt.Printf("    imports %s\n", strings.Join(pkg.Imports, ", "))
+			}
+		}
+	}
+}
+
+// printDot prints a Graphviz graph where every application points to its main
+// package, the packages shared by several applications appear once.
+func printDot(graphs []appGraph) {
+	edges := map[string]bool{}
+
+	fmt.Println("digraph gomon {")
+	fmt.Println("  rankdir=LR;")
+	fmt.Println("  node [shape=box];")
+
+	for _, graph := range graphs {
+		fmt.Printf("  %q [shape=ellipse, style=bold];\n", "app:"+graph.Name)
+		if graph.Main == "" {
+			continue
+		}
+		fmt.Printf("  %q -> %q;\n", "app:"+graph.Name, graph.node(graph.Main))
+
+		for _, pkg := range graph.Packages {
+			for _, importPath := range pkg.Imports {
+				edge := fmt.Sprintf("  %q -> %q;", graph.node(pkg.ImportPath), importPath)
+				if !edges[edge] {
+					edges[edge] = true
+					fmt.Println(edge)
+				}
+			}
+		}
+	}
+
+	fmt.Println("}")
+}
+
+// node returns the name of the package in the DOT graph, the main packages
+// given as a file are all named command-line-arguments by the go command.
+func (g appGraph) node(importPath string) string {
+	if importPath == "command-line-arguments" {
+		return g.Name + ":" + importPath
+	}
+
+	return importPath
+}
+
+func init() {
+	Command.Flags().StringVarP(
+		&fFormat, "format",
+		"f",
+		"text",
+		"the output format: text, json or dot")
+}
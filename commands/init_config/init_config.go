@@ -0,0 +1,151 @@
+package init_config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/expectedsh/gomon/pkg/colors"
+	"github.com/expectedsh/gomon/pkg/config"
+	"github.com/expectedsh/gomon/pkg/gomodule"
+	"github.com/expectedsh/gomon/pkg/imports"
+)
+
+var Command = &cobra.Command{
+	Use:          "init",
+	Short:        "Find the main packages of the module and write a .gomon.yaml to run them",
+	Example:      "gomon init",
+	RunE:         run,
+	SilenceUsage: true,
+}
+
+var fForce bool
+
+type discoveredApp struct {
+	name       string
+	path       string
+	importPath string
+	color      colors.Color
+}
+
+func run(c *cobra.Command, _ []string) error {
+	files, _, err := config.FromFlags(c.Flags())
+	if err != nil {
+		return err
+	}
+
+	file := files[0]
+
+	if _, err := os.Stat(file); err == nil && !fForce {
+		return errors.Errorf("%s already exists, use --force to overwrite it", file)
+	}
+
+	moduleName, err := gomodule.GetName()
+	if err != nil {
+		return errors.Wrap(err, "unable to get gomodule")
+	}
+
+	dirs, err := imports.FindMainPackages(".")
+	if err != nil {
+		return errors.Wrap(err, "unable to find the main packages")
+	}
+
+	if len(dirs) == 0 {
+		return errors.New("there is no main package in this module")
+	}
+
+	apps := discover(moduleName, dirs)
+
+	if err := ioutil.WriteFile(file, []byte(render(moduleName, apps)), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("%s written with %d application(s):\n", file, len(apps))
+	for _, app := range apps {
+		fmt.Printf("  %s: %s\n", app.name, app.path)
+	}
+
+	return nil
+}
+
+// discover proposes a unique name and a distinct color for every main package.
+func discover(moduleName string, dirs []string) []discoveredApp {
+	count := map[string]int{}
+	for _, dir := range dirs {
+		count[baseName(moduleName, dir)]++
+	}
+
+	var apps []discoveredApp
+	for i, dir := range dirs {
+		name := baseName(moduleName, dir)
+		if count[name] > 1 {
+			name = strings.ReplaceAll(filepath.ToSlash(dir), "/", "-")
+		}
+
+		importPath := moduleName
+		if dir != "." {
+			importPath += "/" + filepath.ToSlash(dir)
+		}
+
+		apps = append(apps, discoveredApp{
+			name:       name,
+			path:       filepath.ToSlash(dir),
+			importPath: importPath,
+			color:      colors.Names[i%len(colors.Names)],
+		})
+	}
+
+	return apps
+}
+
+func baseName(moduleName, dir string) string {
+	if dir == "." {
+		return moduleName[strings.LastIndex(moduleName, "/")+1:]
+	}
+
+	return filepath.Base(dir)
+}
+
+func render(moduleName string, apps []discoveredApp) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# gomon config of %s, generated by `gomon init`.\n", moduleName)
+	fmt.Fprintf(&b, "# Check the config with `gomon validate` and run everything with `gomon run`.\n")
+	fmt.Fprintf(&b, "\n")
+	fmt.Fprintf(&b, "# settings:\n")
+	fmt.Fprintf(&b, "#   directories: [\"cmd\", \"pkg\", \"internal\"]\n")
+	fmt.Fprintf(&b, "#   watch_timeout: 1s\n")
+	fmt.Fprintf(&b, "#   kill_timeout: 2s\n")
+	fmt.Fprintf(&b, "#   env_file: .env\n")
+	fmt.Fprintf(&b, "\n")
+	fmt.Fprintf(&b, "apps:\n")
+
+	for i, app := range apps {
+		if i > 0 {
+			fmt.Fprintf(&b, "\n")
+		}
+
+		fmt.Fprintf(&b, "  # %s\n", app.importPath)
+		fmt.Fprintf(&b, "  - name: %q\n", app.name)
+		fmt.Fprintf(&b, "    path: %q\n", app.path)
+		fmt.Fprintf(&b, "    color: %s\n", string(app.color))
+		fmt.Fprintf(&b, "    # args: [\"serve\"]\n")
+		fmt.Fprintf(&b, "    # env:\n")
+		fmt.Fprintf(&b, "    #   LOG_LEVEL: debug\n")
+	}
+
+	return b.String()
+}
+
+func init() {
+	Command.Flags().BoolVarP(
+		&fForce, "force",
+		"f",
+		false,
+		"overwrite the config file if it already exists")
+}
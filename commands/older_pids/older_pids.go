@@ -5,8 +5,8 @@ import (
 
 	"github.com/spf13/cobra"
 
+	"github.com/expectedsh/gomon/pkg/config"
 	"github.com/expectedsh/gomon/pkg/pids"
-	"github.com/expectedsh/gomon/pkg/utils"
 )
 
 var Command = &cobra.Command{
@@ -18,18 +18,12 @@ var Command = &cobra.Command{
 }
 
 func run(c *cobra.Command, _ []string) error {
-	cfg, err := c.Root().Flags().GetString("config")
+	cfg, err := config.LoadFromFlags(c.Flags())
 	if err != nil {
 		return err
 	}
 
-	cfgHash := ""
-
-	if err := utils.InitConfigHash(cfg, &cfgHash); err != nil {
-		return err
-	}
-
-	load, err := pids.Load(cfgHash)
+	load, err := pids.Load(cfg.Hash)
 	if err != nil {
 		return err
 	}
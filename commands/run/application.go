@@ -2,52 +2,178 @@ package run
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/expectedsh/gomon/pkg/colors"
+	"github.com/expectedsh/gomon/pkg/config"
+	"github.com/expectedsh/gomon/pkg/envfile"
+	"github.com/expectedsh/gomon/pkg/gomodule"
 	"github.com/expectedsh/gomon/pkg/imports"
 	"github.com/expectedsh/gomon/pkg/pids"
 	"github.com/expectedsh/gomon/pkg/utils"
 )
 
 type application struct {
-	config         applicationConfig
+	config         config.Application
 	paddingAppName int
-	repo           string
+	moduleDir      string
+	moduleName     string
 	restart        chan bool
+	quit           chan bool
+	done           chan bool
 
 	mutex *sync.Mutex
-	files map[string]bool
 	cmd   *exec.Cmd
+	// changedAt is the time of the change that triggered the next build
+	changedAt time.Time
+	// binSlot is the slot of the binary of the running process, the next
+	// binary is built in the other slot
+	binSlot int
 }
 
-func newApplication(repo string, config applicationConfig, paddingAppName int) *application {
+func newApplication(appConfig config.Application, paddingAppName int) (*application, error) {
 	app := &application{
-		config:         config,
+		config:         appConfig,
 		paddingAppName: paddingAppName,
-		repo:           repo,
-		files:          make(map[string]bool),
 		cmd:            nil,
 		restart:        make(chan bool),
+		quit:           make(chan bool),
+		done:           make(chan bool),
 		mutex:          &sync.Mutex{},
+		changedAt:      time.Now(),
 	}
 
-	app.updateFiles(app.config.Path)
+	// a command has no go package to track
+	if appConfig.IsCommand() {
+		return app, nil
+	}
+
+	moduleDir, err := appConfig.ModuleDir()
+	if err != nil {
+		return nil, err
+	}
+
+	app.moduleDir = moduleDir
+	if app.moduleName, err = gomodule.GetNameFromDir(moduleDir); err != nil {
+		return nil, err
+	}
+
+	if err := app.updateGraph(); err != nil {
+		return nil, err
+	}
+	hashes.Track(app.moduleFiles())
+
+	return app, nil
+}
+
+// stop interrupts the application and waits until it is stopped, the
+// application will not be restarted.
+func (a *application) stop() {
+	close(a.quit)
+	<-a.done
+
+	pids.Remove(a.config.Name)
+	index.Remove(a.config.Name)
+}
+
+// requestRestart asks the application to restart after the change made at
+// changedAt, it does nothing if the application is stopped.
+func (a *application) requestRestart(changedAt time.Time) {
+	a.mutex.Lock()
+	a.changedAt = changedAt
+	a.mutex.Unlock()
+
+	select {
+	case a.restart <- true:
+	case <-a.done:
+	}
+}
+
+// build builds a new binary of the application with the build scheduler, the
+// running process is not affected. It returns the path of the binary.
+func (a *application) build(ctx context.Context) (string, error) {
+	a.mutex.Lock()
+	since := a.changedAt
+	a.mutex.Unlock()
+
+	if a.config.IsCommand() {
+		if a.config.BuildCommand == "" {
+			return "", nil
+		}
 
-	return app
+		if err := builds.build(ctx, a, since, ""); err != nil {
+			return "", errors.Wrap(err, "unable to build application "+a.config.Name)
+		}
+
+		return "", nil
+	}
+
+	// the binary can be a hard link shared with another application
+	bin := a.nextBin()
+	if err := os.RemoveAll(filepath.Dir(bin)); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(bin), os.ModePerm); err != nil {
+		return "", err
+	}
+
+	if err := builds.build(ctx, a, since, bin); err != nil {
+		return "", errors.Wrap(err, "unable to build application "+a.config.Name)
+	}
+
+	return bin, nil
 }
 
-func (a *application) build() error {
-	buildBinaryCmd := exec.Command("go", "build", "-o", a.getBin(), a.config.Path)
+// compile runs go build for the application, the build is killed when ctx is
+// cancelled.
+func (a *application) compile(ctx context.Context, bin string) error {
+	var buildBinaryCmd *exec.Cmd
+
+	if a.config.IsCommand() {
+		env, err := a.environment()
+		if err != nil {
+			return errors.Wrap(err, "unable to load the environment of "+a.config.Name)
+		}
+
+		buildBinaryCmd = shellCommand(a.config.BuildCommand, nil)
+		buildBinaryCmd.Env = env
+		buildBinaryCmd.Dir = a.config.Cwd
+
+		if !fIgnoreBuild {
+			a.log(a.config.BuildCommand, false, "BUILDER")
+		}
+	} else {
+		buildPath := a.config.BuildPath(a.moduleDir)
+
+		buildBinaryCmd = exec.Command("go", a.config.Build.Args(bin, buildPath)...)
+		buildBinaryCmd.Env = append(os.Environ(), a.config.Build.Environ()...)
+		buildBinaryCmd.Dir = a.moduleDir
+		// a cancelled build kills the compilers started by go build too, they
+		// keep the output open
+		setProcessGroup(buildBinaryCmd)
+
+		if !fIgnoreBuild {
+			commandLine := buildCommandLine(a.config.Build, bin, buildPath)
+			if a.moduleDir != "." {
+				commandLine = "cd " + a.moduleDir + " && " + commandLine
+			}
+			a.log(commandLine, false, "BUILDER")
+		}
+	}
 
 	stdout, err := buildBinaryCmd.StdoutPipe()
 	if err != nil {
@@ -59,14 +185,47 @@ func (a *application) build() error {
 		return err
 	}
 
-	go a.handleLog(stdout, false, "BUILDER")
-	go a.handleLog(stderr, true, "BUILDER")
-
 	if err := buildBinaryCmd.Start(); err != nil {
 		return err
 	}
 
-	buildBinaryCmd.Wait()
+	// the output must be read before waiting for the command, the compiler
+	// errors are printed before the result of the build
+	output := sync.WaitGroup{}
+	for _, r := range []struct {
+		pipe  io.ReadCloser
+		error bool
+	}{{stdout, false}, {stderr, true}} {
+		output.Add(1)
+		go func(pipe io.ReadCloser, error bool) {
+			defer output.Done()
+
+			if fIgnoreBuild {
+				_, _ = io.Copy(ioutil.Discard, pipe)
+			} else {
+				a.handleLog(pipe, error, "BUILDER")
+			}
+		}(r.pipe, r.error)
+	}
+
+	// the build is killed when it is cancelled
+	finished := make(chan struct{})
+	defer close(finished)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = signalProcess(buildBinaryCmd, syscall.SIGKILL)
+		case <-finished:
+		}
+	}()
+
+	output.Wait()
+	_ = buildBinaryCmd.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
 
 	if !buildBinaryCmd.ProcessState.Success() {
 		return errors.New("build unsuccessful")
@@ -75,62 +234,94 @@ func (a *application) build() error {
 	return nil
 }
 
-func (a *application) run(exit chan bool) error {
-	if err := a.build(); err != nil {
-		return errors.Wrap(err, "unable to build application "+a.config.Name)
-	}
+// start starts the binary built at bin, the returned channel is closed when
+// the process exits.
+func (a *application) start(bin string) (chan struct{}, error) {
+	a.setBin(bin)
 
-	cmd := exec.Command(a.getBin())
-	a.setCmd(cmd)
-
-	// adding current environment variables
-	for _, val := range os.Environ() {
-		cmd.Env = append(cmd.Env, val)
+	env, err := a.environment()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to load the environment of "+a.config.Name)
 	}
 
-	// adding environment variables from the config
-	for k, v := range a.config.Env {
-		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%v", k, v))
+	args := a.expandArgs(env)
+
+	var cmd *exec.Cmd
+	if a.config.IsCommand() {
+		cmd = shellCommand(a.config.Command, args)
+		commandLine := a.config.Command
+		if len(args) > 0 {
+			commandLine += " " + joinCommandLine(args)
+		}
+		a.log("Starting "+commandLine, false, "GOMON")
+	} else {
+		cmd = exec.Command(bin, args...)
+		a.log("Starting "+joinCommandLine(append([]string{a.config.Name}, args...)), false, "GOMON")
 	}
+	cmd.Env = env
+	cmd.Dir = a.config.Cwd
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	go a.handleLog(stderr, true, "")
 	go a.handleLog(stdout, false, "")
 
 	if err := cmd.Start(); err != nil {
-		return err
+		return nil, err
 	}
+	a.setCmd(cmd)
 
 	time.AfterFunc(time.Millisecond*500, func() {
 		pids.Add(a.config.Name, cmd)
 	})
 
+	exit := make(chan struct{})
+
 	go func() {
 		_ = cmd.Wait()
 
-		success := cmd.ProcessState.Success()
-		exitCode := cmd.ProcessState.ExitCode()
-
-		if success {
+		if cmd.ProcessState.Success() {
 			a.log("successfully exited", false, "")
 		} else {
-			a.log(fmt.Sprintf("exited with code: %d", exitCode), true, "")
+			a.log(fmt.Sprintf("exited with code: %d", cmd.ProcessState.ExitCode()), true, "")
 		}
 
-		exit <- success
 		close(exit)
 	}()
 
-	return nil
+	return exit, nil
+}
+
+// expandArgs replaces $VAR, ${VAR} and ${VAR:-default} in the arguments of the
+// application with the values of the environment given to the process, like
+// the env files.
+func (a *application) expandArgs(env []string) []string {
+	values := map[string]string{}
+	for _, kv := range env {
+		if i := strings.Index(kv, "="); i >= 0 {
+			values[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	lookup := func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+
+	var args []string
+	for _, arg := range a.config.Args {
+		args = append(args, envfile.Expand(arg, lookup))
+	}
+
+	return args
 }
 
 func (a *application) handleLog(r io.ReadCloser, error bool, prefix string) {
@@ -149,28 +340,29 @@ func (a *application) handleLog(r io.ReadCloser, error bool, prefix string) {
 }
 
 func (a *application) log(line string, error bool, prefix string) {
+	s := currentSettings()
 	lineToPrint := ""
 
-	if fColors {
+	if s.colors {
 		lineToPrint += a.config.Color.String()
 	}
 
 	hasPid := false
-	if fPid {
+	if s.pid {
 		if pid, err := a.getPid(); err == nil {
 			hasPid = true
 			lineToPrint += fmt.Sprintf("%05d ", pid)
 		}
 	}
 
-	if !hasPid && fPid {
+	if !hasPid && s.pid {
 		lineToPrint += "      "
 	}
 
 	lineToPrint += fmt.Sprintf(fmt.Sprintf("%%-%ds |", a.paddingAppName), a.config.Name)
 
 	if error && !fIgnoreError {
-		if fColors {
+		if s.colors {
 			lineToPrint += colors.Bold.String() + colors.Red.String()
 		}
 
@@ -178,14 +370,14 @@ func (a *application) log(line string, error bool, prefix string) {
 	}
 
 	if prefix != "" {
-		if fColors {
+		if s.colors {
 			lineToPrint += colors.Reset.String() + colors.Bold.String()
 		}
 
 		lineToPrint += " " + strings.ToUpper(prefix) + ":"
 	}
 
-	if fColors {
+	if s.colors {
 		lineToPrint += colors.Reset.String()
 	}
 
@@ -198,8 +390,48 @@ func (a *application) log(line string, error bool, prefix string) {
 	fmt.Print(lineToPrint)
 }
 
-func (a application) getBin() string {
-	return path.Join(utils.GetGomonBuilds(cfgHash), a.config.Name)
+// gomonLog prints a message that is not related to a specific application.
+func gomonLog(line string, error bool) {
+	logger("gomon").log(line, error, "GOMON")
+}
+
+// logger returns an application only used to print messages with the name.
+func logger(name string) *application {
+	return &application{
+		config:         config.Application{Name: name, Color: colors.Bold},
+		paddingAppName: getAppPadding(),
+		mutex:          &sync.Mutex{},
+	}
+}
+
+// getBin returns the binary of the running process.
+func (a *application) getBin() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.binPath(a.binSlot)
+}
+
+// nextBin returns a binary path that is not used by the running process, the
+// new binary is built there while the process keeps running.
+func (a *application) nextBin() string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.binPath(1 - a.binSlot)
+}
+
+func (a *application) setBin(bin string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if bin == a.binPath(1-a.binSlot) {
+		a.binSlot = 1 - a.binSlot
+	}
+}
+
+func (a *application) binPath(slot int) string {
+	return path.Join(utils.GetGomonBuilds(cfgHash), a.config.Name+"."+strconv.Itoa(slot), a.config.Name)
 }
 
 func (a *application) getPid() (int, error) {
@@ -230,16 +462,208 @@ func (a *application) setCmd(cmd *exec.Cmd) {
 	a.cmd = cmd
 }
 
-func (a *application) updateFiles(startFile string) {
-	a.mutex.Lock()
-	defer a.mutex.Unlock()
+// importsOptions returns the options of the build of the application.
+func (a *application) importsOptions() imports.Options {
+	return imports.OptionsFor(a.config, a.moduleDir)
+}
+
+// updateGraph lists again the packages built into the application.
+func (a *application) updateGraph() error {
+	graph, err := imports.Load(a.config.BuildPath(a.moduleDir), a.importsOptions())
+	if err != nil {
+		return err
+	}
+
+	index.Set(a.config.Name, graph)
+	trackFiles(graph)
 
-	imports.FromFile(a.repo, startFile, a.files)
+	return nil
 }
 
-func (a *application) getFiles() map[string]bool {
-	a.mutex.Lock()
-	defer a.mutex.Unlock()
+// refreshGraph updates the graph after a change on a go file. The package of
+// the file and its dependencies are listed again when the imports of the file
+// changed, when the file was removed, or when it is a new file of a package of
+// the application. It reports whether the graph was updated.
+func (a *application) refreshGraph(file string, removed bool) (bool, error) {
+	if !strings.HasSuffix(file, ".go") || strings.HasSuffix(file, "_test.go") {
+		return false, nil
+	}
+
+	importPath, ok := index.Package(a.config.Name, file)
+	switch {
+	case ok && !removed && !index.ImportsChanged(a.config.Name, file):
+		return false, nil
+	case !ok && removed:
+		return false, nil
+	case !ok:
+		if importPath, ok = index.PackageInDir(a.config.Name, filepath.Dir(file)); !ok {
+			return false, nil
+		}
+
+		if a.isExcluded(file) || !a.importsOptions().MatchFile(file) {
+			return false, nil
+		}
+	}
+
+	graph, ok := index.Graph(a.config.Name)
+	if !ok {
+		return false, nil
+	}
+
+	updated, err := graph.Update(a.config.BuildPath(a.moduleDir), importPath, a.importsOptions())
+	if err != nil {
+		return false, err
+	}
+
+	index.Set(a.config.Name, updated)
+	trackFiles(updated)
+
+	return true, nil
+}
+
+// trackFiles hashes the files of the graph, their writes that do not change
+// them are ignored.
+func trackFiles(graph *imports.Graph) {
+	files := make([]string, 0, len(graph.Files))
+	for file := range graph.Files {
+		files = append(files, file)
+	}
+
+	hashes.Track(files)
+}
+
+// moduleFiles returns the files describing the dependencies of the
+// application: the go.mod, go.sum and vendor/modules.txt of its module and of
+// the local modules it imports, and the go.work of the workspace.
+func (a *application) moduleFiles() []string {
+	if a.config.IsCommand() {
+		return nil
+	}
+
+	graph, _ := index.Graph(a.config.Name)
+	return imports.ModuleFiles(a.moduleDir, graph)
+}
+
+func (a *application) isModuleFile(file string) bool {
+	if !gomodule.IsFile(file) {
+		return false
+	}
+
+	for _, moduleFile := range a.moduleFiles() {
+		if filepath.Clean(moduleFile) == file {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reloadDependencies lists again the packages of the application after a
+// change on its module files, it reports whether the packages or the versions
+// of the modules changed.
+func (a *application) reloadDependencies() (bool, error) {
+	if name, err := gomodule.GetNameFromDir(a.moduleDir); err == nil && name != a.moduleName {
+		a.log(fmt.Sprintf("The module was renamed from %s to %s", a.moduleName, name), false, "GOMON")
+		a.moduleName = name
+	}
+
+	previous, ok := index.Graph(a.config.Name)
+
+	if err := a.updateGraph(); err != nil {
+		return true, err
+	}
+
+	graph, _ := index.Graph(a.config.Name)
+
+	return !ok || !previous.SameDependencies(graph), nil
+}
+
+func (a *application) isImported(file string) bool {
+	_, ok := index.Package(a.config.Name, file)
+	return ok
+}
+
+// isEmbedded reports whether the file matches a //go:embed directive of one of
+// the packages of the application.
+func (a *application) isEmbedded(file string) bool {
+	return index.Embeds(a.config.Name, file)
+}
+
+// isExcluded reports whether the file or directory matches one of the
+// directories_to_exclude or files_to_exclude patterns of the application.
+func (a *application) isExcluded(file string) bool {
+	return a.config.IsExcluded(file)
+}
+
+// isWatched reports whether a change on this file must restart the
+// application.
+func (a *application) isWatched(file string) bool {
+	if a.isExcluded(file) {
+		return false
+	}
+
+	if !a.isImported(file) && !a.isEmbedded(file) && !a.isEnvFile(file) &&
+		!a.config.MatchesWatchPatterns(file) {
+		return false
+	}
+
+	// go files excluded by their build constraints are not built, the file
+	// is only read once the cheaper checks matched
+	return a.config.IsCommand() || a.importsOptions().MatchFile(file)
+}
+
+// watchRoots returns the directories that need to be registered in the
+// watcher to detect changes on the patterns to watch.
+func (a *application) watchRoots() []string {
+	var roots []string
+
+	// the default directories are relative to the module of the application
+	if !a.config.IsCommand() && a.moduleDir != "." {
+		for _, directory := range currentSettings().directories {
+			roots = append(roots, filepath.Join(a.moduleDir, directory))
+		}
+	}
+
+	for _, pattern := range a.config.DirectoriesToWatch {
+		roots = append(roots, utils.GlobBase(pattern))
+	}
+
+	for _, pattern := range a.config.FilesToWatch {
+		roots = append(roots, utils.GlobBase(pattern))
+	}
+
+	// new files can be created in the directories of the embed patterns
+	for _, pkg := range index.Packages(a.config.Name) {
+		roots = append(roots, pkg.EmbedRoots()...)
+	}
+
+	for i, root := range roots {
+		if info, err := os.Stat(root); err == nil && !info.IsDir() {
+			roots[i] = filepath.Dir(root)
+		}
+	}
+
+	return roots
+}
+
+// watchDirectories returns the directories to register in the watcher without
+// their sub directories.
+func (a *application) watchDirectories() []string {
+	var directories []string
+
+	for _, file := range append(append([]string{}, currentSettings().envFiles...), a.config.EnvFile...) {
+		directories = append(directories, filepath.Dir(file))
+	}
+
+	for _, file := range a.moduleFiles() {
+		directories = append(directories, filepath.Dir(file))
+	}
+
+	// the packages can be outside of the watched directories, in a module of
+	// the workspace or a module replaced by a local directory
+	for _, pkg := range index.Packages(a.config.Name) {
+		directories = append(directories, pkg.Dir)
+	}
 
-	return a.files
+	return directories
 }
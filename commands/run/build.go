@@ -0,0 +1,28 @@
+package run
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/expectedsh/gomon/pkg/config"
+)
+
+// buildCommandLine returns a human readable version of the build command.
+func buildCommandLine(b config.Build, bin, path string) string {
+	return joinCommandLine(append(append(b.Environ(), "go"), b.Args(bin, path)...))
+}
+
+// joinCommandLine quotes the parts containing spaces or quotes and joins them
+// to be displayed.
+func joinCommandLine(parts []string) string {
+	var quoted []string
+
+	for _, part := range parts {
+		if part == "" || strings.ContainsAny(part, " \t\"'") {
+			part = strconv.Quote(part)
+		}
+		quoted = append(quoted, part)
+	}
+
+	return strings.Join(quoted, " ")
+}
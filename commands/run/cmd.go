@@ -2,6 +2,7 @@ package run
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"sync"
@@ -11,10 +12,10 @@ import (
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
-	"github.com/expectedsh/gomon/pkg/colors"
-	"github.com/expectedsh/gomon/pkg/gomodule"
+	"github.com/expectedsh/gomon/pkg/config"
+	"github.com/expectedsh/gomon/pkg/contents"
+	"github.com/expectedsh/gomon/pkg/imports"
 	"github.com/expectedsh/gomon/pkg/pids"
-	"github.com/expectedsh/gomon/pkg/utils"
 )
 
 var Command = &cobra.Command{
@@ -26,44 +27,45 @@ var Command = &cobra.Command{
 }
 
 var (
-	fPid          bool
-	fColors       bool
-	fIgnoreError  bool
-	fDirectories  []string
-	fWatchTimeout time.Duration
-	fKillTimeout  time.Duration
+	fPid            bool
+	fColors         bool
+	fIgnoreError    bool
+	fIgnoreBuild    bool
+	fIgnoreComments bool
+	fBuildWorkers   int
+	fGoGenerate     bool
+	fDirectories    []string
+	fEnvFiles       []string
+	fWatchTimeout   time.Duration
+	fKillTimeout    time.Duration
 )
 
-type applicationConfig struct {
-	Name           string            `yaml:"name"`
-	Path           string            `yaml:"path"`
-	Env            map[string]string `yaml:"env"`
-	Color          colors.Color      `yaml:"color"`
-	MustNotRestart bool              `yaml:"must_not_restart"`
-
-	// \/ \/ theses options are not handled currently \/ \/
-
-	DirectoriesToWatch   []string `yaml:"directories_to_watch"`
-	DirectoriesToExclude []string `yaml:"directories_to_exclude"`
-
-	FilesToWatch   []string `yaml:"files_to_watch"`
-	FilesToExclude []string `yaml:"files_to_exclude"`
-}
-
 var cfgHash string
-var applicationConfigList []applicationConfig
+var cfgFiles []string
+var cfgProfile string
+var cfgSources []string
+var applicationConfigList []config.Application
+var cfgGenerators []config.Generator
 var applications = map[string]*application{}
 
+// index associates the files to the applications built with them.
+var index = imports.NewIndex()
+
+// hashes detects the writes that do not change the files.
+var hashes = contents.NewHashes()
+
 func run(c *cobra.Command, _ []string) error {
-	cfg, err := c.Root().Flags().GetString("config")
+	cfg, err := config.LoadFromFlags(c.Flags())
 	if err != nil {
-		return err
-	}
-
-	if err := utils.InitConfig(cfg, &cfgHash, &applicationConfigList); err != nil {
 		return errors.Wrap(err, "unable to get config file")
 	}
 
+	cfgHash, applicationConfigList, cfgGenerators = cfg.Hash, cfg.Apps, cfg.Generators
+	cfgFiles, cfgProfile, cfgSources = cfg.Files, cfg.Profile, cfg.Sources
+
+	initSettings(c.Flags())
+	applySettings(cfg.Settings)
+
 	if err := pids.Kill(cfgHash); err != nil {
 		return errors.Wrap(err, "unable to kill old pid run by gomon")
 	}
@@ -72,25 +74,27 @@ func run(c *cobra.Command, _ []string) error {
 		return errors.New("there is no application to run")
 	}
 
-	moduleName, err := gomodule.GetName()
-	if err != nil {
-		return errors.Wrap(err, "unable to get gomodule")
-	}
-
 	var (
 		appPadding     = getAppPadding()
 		wg             = sync.WaitGroup{}
 		ctx, cancelCtx = context.WithCancel(context.Background())
 	)
 
-	for _, config := range applicationConfigList {
-		app := newApplication(moduleName, config, appPadding)
-		applications[config.Name] = app
+	defer cancelCtx()
+
+	for _, appConfig := range applicationConfigList {
+		app, err := newApplication(appConfig, appPadding)
+		if err != nil {
+			return err
+		}
+		applications[appConfig.Name] = app
+	}
 
-		go handleRunningApplication(ctx, &wg, app)
+	for _, app := range applications {
+		startApplication(ctx, &wg, app)
 	}
 
-	if err := newWatcher(ctx).watchForRestarts(); err != nil {
+	if err := newWatcher(ctx, &wg).watchForRestarts(); err != nil {
 		return err
 	}
 
@@ -112,32 +116,174 @@ func run(c *cobra.Command, _ []string) error {
 	return nil
 }
 
-func handleRunningApplication(ctx context.Context, wg *sync.WaitGroup, app *application) {
+func startApplication(ctx context.Context, wg *sync.WaitGroup, app *application) {
 	wg.Add(1)
 
-	for {
-		exit := make(chan bool)
-		if err := app.run(exit); err != nil {
-			app.log("This application could not be run because it encountered an error.", true, "GOMON")
-			app.log("Waiting for a restart", true, "GOMON")
+	go handleRunningApplication(ctx, wg, app)
+}
+
+const (
+	// minRestartDelay is the delay before restarting a process that exited
+	// on its own twice in a row, it doubles on every exit up to
+	// maxRestartDelay.
+	minRestartDelay = 500 * time.Millisecond
+	maxRestartDelay = 30 * time.Second
+	// a process running longer than restartDelayReset is restarted right
+	// away when it exits.
+	restartDelayReset = 10 * time.Second
+)
+
+// buildResult is the result of a build running in the background.
+type buildResult struct {
+	bin string
+	err error
+}
+
+func handleRunningApplication(ctx context.Context, wg *sync.WaitGroup, app *application) {
+	defer wg.Done()
+	defer close(app.done)
+
+	var (
+		// exit is nil while no process is running
+		exit chan struct{}
+		// built is nil while no build is running
+		built       chan buildResult
+		cancelBuild context.CancelFunc
+		// rebuild is true when a change arrived during a cancelled build
+		rebuild bool
+
+		startedAt time.Time
+		// restartDelay is the delay before the next restart of a process that
+		// exited on its own, restartAfter is nil while no restart is delayed
+		restartDelay time.Duration
+		restartAfter <-chan time.Time
+	)
+
+	start := func(bin string) {
+		restartAfter = nil
+		startedAt = time.Now()
+		exit = startProcess(app, bin)
+	}
+
+	startBuild := func() {
+		var buildCtx context.Context
+		buildCtx, cancelBuild = context.WithCancel(ctx)
+
+		built = make(chan buildResult, 1)
+		go func(built chan buildResult) {
+			bin, err := app.build(buildCtx)
+			built <- buildResult{bin: bin, err: err}
+		}(built)
+	}
+
+	// stopBuild cancels the running build and waits until it is stopped.
+	stopBuild := func() {
+		if built != nil {
+			cancelBuild()
+			<-built
 		}
+	}
 
+	startBuild()
+
+	for {
 		select {
 		case <-ctx.Done():
+			stopBuild()
 			stopApp(false, app, exit)
-			wg.Done()
+			return
+		case <-app.quit:
+			stopBuild()
+			stopApp(true, app, exit)
 			return
 		case <-app.restart:
+			if built != nil {
+				// only one build is queued with the latest changes
+				if !rebuild {
+					app.log("A newer change arrived, cancelling the build", false, "BUILDER")
+					cancelBuild()
+					rebuild = true
+				}
+				continue
+			}
+
+			// the new binary is built while the previous process keeps
+			// running, it is only replaced when the build succeeds
+			startBuild()
+		case result := <-built:
+			built = nil
+			cancelBuild()
+
+			if rebuild {
+				rebuild = false
+				startBuild()
+				continue
+			}
+
+			if result.err != nil {
+				app.log(result.err.Error(), true, "GOMON")
+				if exit != nil {
+					app.log("The build failed, the previous process keeps running.", true, "GOMON")
+				}
+				app.log("Waiting for a restart", true, "GOMON")
+				continue
+			}
+
 			stopApp(true, app, exit)
+			start(result.bin)
 		case <-exit:
+			exit = nil
 			if app.config.MustNotRestart {
-				wg.Done()
+				stopBuild()
 				return
 			}
+
+			// a process exiting right after its start is restarted with a
+			// growing delay instead of looping
+			if time.Since(startedAt) >= restartDelayReset {
+				restartDelay = 0
+			}
+
+			if built != nil {
+				continue
+			}
+
+			if restartDelay == 0 {
+				start(app.getBin())
+			} else {
+				app.log(fmt.Sprintf("Restarting in %s", restartDelay), false, "GOMON")
+				restartAfter = time.After(restartDelay)
+			}
+
+			restartDelay *= 2
+			if restartDelay < minRestartDelay {
+				restartDelay = minRestartDelay
+			} else if restartDelay > maxRestartDelay {
+				restartDelay = maxRestartDelay
+			}
+		case <-restartAfter:
+			restartAfter = nil
+			if exit == nil && built == nil {
+				start(app.getBin())
+			}
 		}
 	}
 }
 
+// startProcess starts the binary of the application, it returns nil when the
+// process could not be started.
+func startProcess(app *application, bin string) chan struct{} {
+	exit, err := app.start(bin)
+	if err != nil {
+		app.log("This application could not be run because it encountered an error.", true, "GOMON")
+		app.log(err.Error(), true, "GOMON")
+		app.log("Waiting for a restart", true, "GOMON")
+		return nil
+	}
+
+	return exit
+}
+
 func init() {
 	Command.Flags().BoolVarP(
 		&fColors, "colors",
@@ -158,17 +304,37 @@ func init() {
 		"output error like normal message")
 
 	Command.Flags().BoolVarP(
-		&fIgnoreError, "ignore-build",
+		&fIgnoreBuild, "ignore-build",
 		"b",
 		false,
 		"ignore build output")
 
+	Command.Flags().BoolVar(
+		&fIgnoreComments, "ignore-comments",
+		false,
+		"do not restart when only the comments or the spacing of a go file changed")
+
+	Command.Flags().IntVar(
+		&fBuildWorkers, "build-workers",
+		0,
+		"the maximum number of builds running at once, the number of CPUs by default")
+
+	Command.Flags().BoolVar(
+		&fGoGenerate, "go-generate",
+		false,
+		"run go generate for the //go:generate directives referencing a changed file")
+
 	Command.Flags().StringArrayVarP(
 		&fDirectories, "directories",
 		"d",
 		[]string{"cmd", "pkg", "internal"},
 		"list of directories to watch by default")
 
+	Command.Flags().StringArrayVar(
+		&fEnvFiles, "env-file",
+		nil,
+		"env files loaded by every app, before the env files of the app")
+
 	Command.Flags().DurationVarP(
 		&fWatchTimeout, "watch-timeout",
 		"t",
@@ -176,48 +342,42 @@ func init() {
 		"the duration after a change to restart an app")
 
 	Command.Flags().DurationVarP(
-		&fWatchTimeout, "kill-timeout",
+		&fKillTimeout, "kill-timeout",
 		"k",
 		time.Second*2,
 		"kill the program after this duration if it is living after a sigint")
-
 }
 
-func stopApp(sigint bool, app *application, exit chan bool) {
-
-	ctx, cancelFunc := context.WithTimeout(context.Background(), time.Second*2)
-	defer cancelFunc()
-
+// stopApp stops the process of the application and waits until it exited,
+// exit is the channel returned when the process started. The process is killed
+// if it is still running after the kill timeout.
+func stopApp(sigint bool, app *application, exit chan struct{}) {
 	cmd := app.getCmd()
-	if cmd == nil || cmd != nil && cmd.ProcessState != nil && cmd.ProcessState.Exited() {
+	if cmd == nil || exit == nil {
 		return
 	}
 
-	if sigint && cmd != nil {
-		if err := cmd.Process.Signal(syscall.SIGINT); err != nil {
-			if cmd.ProcessState.Exited() {
-				return
-			}
-		}
+	select {
+	case <-exit:
+		return
+	default:
 	}
 
-	alreadyForceKill := false
+	// a process in its own group does not receive the interrupt of the
+	// terminal
+	if sigint || hasProcessGroup(cmd) {
+		_ = signalProcess(cmd, syscall.SIGINT)
+	}
 
-	for {
-		if alreadyForceKill {
-			<-exit
-			return
-		}
+	timer := time.NewTimer(currentSettings().killTimeout)
+	defer timer.Stop()
 
-		select {
-		case <-ctx.Done():
-			app.log("This app take too long to be interrupted, so gomon will kill it.", false, "GOMON")
-			cmd.Process.Kill()
-			alreadyForceKill = true
-			break
-		case <-exit:
-			return
-		}
+	select {
+	case <-exit:
+	case <-timer.C:
+		app.log("This app take too long to be interrupted, so gomon will kill it.", false, "GOMON")
+		_ = signalProcess(cmd, syscall.SIGKILL)
+		<-exit
 	}
 }
 
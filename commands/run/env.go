@@ -0,0 +1,112 @@
+package run
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/expectedsh/gomon/pkg/envfile"
+)
+
+// environment resolves the environment of the application. From the lowest to
+// the highest precedence: the gomon process environment, the global env files,
+// the env of the settings, the env files of the application and the env of the
+// application.
+func (a *application) environment() ([]string, error) {
+	values, err := globalValues()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := loadEnvFiles(a.config.EnvFile, values); err != nil {
+		return nil, err
+	}
+
+	setEnv(a.config.Env, values)
+
+	return environ(values), nil
+}
+
+// globalValues returns the environment shared by every application: the gomon
+// process environment, the global env files and the env of the settings.
+func globalValues() (map[string]string, error) {
+	values := map[string]string{}
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i >= 0 {
+			values[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	s := currentSettings()
+	if err := loadEnvFiles(s.envFiles, values); err != nil {
+		return nil, err
+	}
+
+	setEnv(s.env, values)
+
+	return values, nil
+}
+
+// environ returns the values as sorted KEY=value pairs.
+func environ(values map[string]string) []string {
+	var env []string
+	for k, v := range values {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	sort.Strings(env)
+
+	return env
+}
+
+func loadEnvFiles(files []string, values map[string]string) error {
+	lookup := func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+
+	for _, file := range files {
+		fileValues, err := envfile.Read(file, lookup)
+		if err != nil {
+			return err
+		}
+
+		for k, v := range fileValues {
+			values[k] = v
+		}
+	}
+
+	return nil
+}
+
+// setEnv interpolates the env with the values previously defined and adds them
+// to values.
+func setEnv(env map[string]string, values map[string]string) {
+	lookup := func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+
+	expanded := map[string]string{}
+	for k, v := range env {
+		expanded[k] = envfile.Expand(v, lookup)
+	}
+
+	for k, v := range expanded {
+		values[k] = v
+	}
+}
+
+// isEnvFile reports whether the file is one of the env files loaded by the
+// application.
+func (a *application) isEnvFile(file string) bool {
+	for _, envFile := range append(append([]string{}, currentSettings().envFiles...), a.config.EnvFile...) {
+		if filepath.Clean(envFile) == filepath.Clean(file) {
+			return true
+		}
+	}
+
+	return false
+}
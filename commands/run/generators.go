@@ -0,0 +1,253 @@
+package run
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"github.com/expectedsh/gomon/pkg/config"
+	"github.com/expectedsh/gomon/pkg/utils"
+)
+
+// generation is a go generate run on the files of a package whose directives
+// reference a changed file.
+type generation struct {
+	dir   string
+	files []string
+}
+
+// generatorsFor returns the generators with an input in the files, and the go
+// generate runs when it is enabled. The generators that already ran are not
+// returned again, so a generator writing its own inputs does not loop.
+func generatorsFor(files []string, ran map[string]bool) ([]config.Generator, []generation) {
+	var generators []config.Generator
+	for _, generator := range cfgGenerators {
+		if ran[generator.Name] {
+			continue
+		}
+
+		for _, file := range files {
+			if matchAny(generator.Inputs, file) && !matchAny(generator.Outputs, file) {
+				ran[generator.Name] = true
+				generators = append(generators, generator)
+				break
+			}
+		}
+	}
+
+	if !currentSettings().goGenerate {
+		return generators, nil
+	}
+
+	var generations []generation
+	for _, g := range goGenerateFor(files) {
+		key := "go generate " + g.dir
+		if !ran[key] {
+			ran[key] = true
+			generations = append(generations, g)
+		}
+	}
+
+	return generators, generations
+}
+
+func matchAny(patterns []string, file string) bool {
+	for _, pattern := range patterns {
+		if utils.MatchGlobDir(pattern, file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// goGenerateFor returns the go files, by package, whose //go:generate
+// directives reference one of the files. Only the packages of the graphs of
+// the applications are read.
+func goGenerateFor(files []string) []generation {
+	dirs := map[string]bool{}
+	var generations []generation
+
+	for name := range applications {
+		for _, pkg := range index.Packages(name) {
+			if dirs[pkg.Dir] {
+				continue
+			}
+			dirs[pkg.Dir] = true
+
+			g := generation{dir: pkg.Dir}
+			for _, goFile := range pkg.Files {
+				if strings.HasSuffix(goFile, ".go") && referencesAny(goFile, pkg.Dir, files) {
+					g.files = append(g.files, filepath.Base(goFile))
+				}
+			}
+
+			if len(g.files) > 0 {
+				generations = append(generations, g)
+			}
+		}
+	}
+
+	sort.Slice(generations, func(i, j int) bool {
+		return generations[i].dir < generations[j].dir
+	})
+
+	return generations
+}
+
+// referencesAny reports whether a //go:generate directive of the go file uses
+// one of the files as an argument, relative to the directory of its package.
+func referencesAny(goFile, dir string, files []string) bool {
+	f, err := os.Open(goFile)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "//go:generate ") {
+			continue
+		}
+
+		for _, file := range files {
+			if file != goFile && references(strings.TrimPrefix(line, "//go:generate "), dir, file) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func references(directive, dir, file string) bool {
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return false
+	}
+
+	for _, field := range strings.Fields(directive) {
+		// -source=schema.sql
+		if i := strings.LastIndex(field, "="); i >= 0 {
+			field = field[i+1:]
+		}
+
+		field = strings.Trim(field, `"'`)
+		if field != "" && filepath.Clean(field) == rel {
+			return true
+		}
+	}
+
+	return false
+}
+
+// runGenerators runs the generators and the go generate runs one after the
+// other and waits for them. A failure is reported, the changes are processed
+// anyway.
+func runGenerators(ctx context.Context, generators []config.Generator, generations []generation) {
+	for _, generator := range generators {
+		out := logger(generator.Name)
+		out.log("Running "+generator.Command, false, "GENERATOR")
+
+		cmd := shellCommand(generator.Command, nil)
+		cmd.Dir = generator.Cwd
+
+		if err := runGenerator(ctx, out, cmd); err != nil {
+			out.log(err.Error(), true, "GENERATOR")
+		}
+	}
+
+	for _, g := range generations {
+		out := logger("gomon")
+		out.log("Running go generate "+strings.Join(g.files, " ")+" in "+g.dir, false, "GENERATOR")
+
+		cmd := exec.Command("go", append([]string{"generate"}, g.files...)...)
+		cmd.Dir = g.dir
+
+		if err := runGenerator(ctx, out, cmd); err != nil {
+			out.log(err.Error(), true, "GENERATOR")
+		}
+	}
+}
+
+// runGenerator runs the command with the global environment, its output is
+// printed by out. The command is killed when the context is done.
+func runGenerator(ctx context.Context, out *application, cmd *exec.Cmd) error {
+	values, err := globalValues()
+	if err != nil {
+		return err
+	}
+	cmd.Env = environ(values)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return errors.Wrap(err, "unable to get the standard output of the generator")
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return errors.Wrap(err, "unable to get the error output of the generator")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return errors.Wrap(err, "unable to start the generator")
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = signalProcess(cmd, syscall.SIGKILL)
+		case <-done:
+		}
+	}()
+
+	// the output must be read before waiting for the command
+	wg := sync.WaitGroup{}
+	for _, r := range []struct {
+		pipe  io.ReadCloser
+		error bool
+	}{{stdout, false}, {stderr, true}} {
+		wg.Add(1)
+		go func(pipe io.ReadCloser, error bool) {
+			defer wg.Done()
+			out.handleLog(pipe, error, "GENERATOR")
+		}(r.pipe, r.error)
+	}
+	wg.Wait()
+
+	if err := cmd.Wait(); err != nil {
+		return errors.Wrap(err, "the generator failed")
+	}
+
+	return nil
+}
+
+// generatorRoots returns the directories of the inputs and the outputs of the
+// generators, a new output directory is watched as soon as it is created.
+func generatorRoots() []string {
+	var roots []string
+	for _, generator := range cfgGenerators {
+		for _, pattern := range append(append([]string{}, generator.Inputs...), generator.Outputs...) {
+			root := utils.GlobBase(pattern)
+			if info, err := os.Stat(root); err == nil && !info.IsDir() {
+				root = filepath.Dir(root)
+			}
+			roots = append(roots, root)
+		}
+	}
+
+	return roots
+}
@@ -0,0 +1,58 @@
+package run
+
+import (
+	"path/filepath"
+
+	"github.com/expectedsh/gomon/pkg/utils"
+)
+
+// watchPatterns associates the base directories of the patterns to watch, and
+// the env files, to the applications using them. A change is only matched
+// against the patterns of the applications that can match it.
+type watchPatterns struct {
+	dirs  map[string]map[string]bool
+	files map[string]map[string]bool
+}
+
+func newWatchPatterns() *watchPatterns {
+	p := &watchPatterns{
+		dirs:  map[string]map[string]bool{},
+		files: map[string]map[string]bool{},
+	}
+
+	envFiles := currentSettings().envFiles
+
+	for name, app := range applications {
+		for _, pattern := range append(append([]string{}, app.config.DirectoriesToWatch...), app.config.FilesToWatch...) {
+			addName(p.dirs, filepath.Clean(utils.GlobBase(pattern)), name)
+		}
+
+		for _, file := range append(append([]string{}, envFiles...), app.config.EnvFile...) {
+			addName(p.files, filepath.Clean(file), name)
+		}
+	}
+
+	return p
+}
+
+func addName(m map[string]map[string]bool, key, name string) {
+	if m[key] == nil {
+		m[key] = map[string]bool{}
+	}
+	m[key][name] = true
+}
+
+// candidates adds to found the applications whose patterns or env files can
+// match the file.
+func (p *watchPatterns) candidates(file string, found map[string]bool) {
+	for name := range p.files[file] {
+		found[name] = true
+	}
+
+	// the base of a pattern without meta character is the file itself
+	for _, dir := range append([]string{file}, utils.Ancestors(file)...) {
+		for name := range p.dirs[dir] {
+			found[name] = true
+		}
+	}
+}
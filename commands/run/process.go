@@ -0,0 +1,39 @@
+package run
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command in its own process group, a shell command
+// and the processes it starts are signaled together.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func hasProcessGroup(cmd *exec.Cmd) bool {
+	return cmd.SysProcAttr != nil && cmd.SysProcAttr.Setpgid
+}
+
+// signalProcess sends the signal to the process, or to its whole group when it
+// has its own.
+func signalProcess(cmd *exec.Cmd, sig syscall.Signal) error {
+	if hasProcessGroup(cmd) {
+		return syscall.Kill(-cmd.Process.Pid, sig)
+	}
+
+	return cmd.Process.Signal(sig)
+}
+
+// shellCommand returns a command running the command line with sh, the
+// arguments are appended to the command line.
+func shellCommand(commandLine string, args []string) *exec.Cmd {
+	if len(args) > 0 {
+		commandLine += ` "$@"`
+	}
+
+	cmd := exec.Command("sh", append([]string{"-c", commandLine, "sh"}, args...)...)
+	setProcessGroup(cmd)
+
+	return cmd
+}
@@ -0,0 +1,92 @@
+package run
+
+import (
+	"path/filepath"
+	"reflect"
+
+	"github.com/expectedsh/gomon/pkg/config"
+)
+
+// reloadConfig loads the config files again and applies the differences with
+// the running applications: new applications are started, removed ones are
+// stopped and the ones with different settings are restarted. The watcher
+// mutex must be held.
+func (w *watcher) reloadConfig() {
+	cfg, err := config.Load(cfgFiles, cfgProfile)
+	if err != nil {
+		gomonLog("The config could not be reloaded, the running applications are kept.", true)
+		gomonLog(err.Error(), true)
+		return
+	}
+
+	gomonLog("The config changed, reloading ...", false)
+
+	// the default environment is shared by every application
+	previous := currentSettings()
+	applySettings(cfg.Settings)
+	current := currentSettings()
+	envChanged := !reflect.DeepEqual(previous.env, current.env) || !reflect.DeepEqual(previous.envFiles, current.envFiles)
+
+	configs := map[string]config.Application{}
+	for _, appConfig := range cfg.Apps {
+		configs[appConfig.Name] = appConfig
+	}
+
+	for name, app := range applications {
+		appConfig, ok := configs[name]
+		if ok && !envChanged && reflect.DeepEqual(app.config, appConfig) {
+			continue
+		}
+
+		if ok {
+			app.log("Its config changed, restarting ...", false, "GOMON")
+		} else {
+			app.log("Removed from the config, stopping ...", false, "GOMON")
+		}
+
+		app.stop()
+		delete(applications, name)
+		delete(w.appsToRestart, name)
+	}
+
+	applicationConfigList = cfg.Apps
+	cfgGenerators = cfg.Generators
+	cfgSources = cfg.Sources
+	// the padding only grows to stay aligned with the applications still running
+	appPadding := getAppPadding()
+	for _, app := range applications {
+		if app.paddingAppName > appPadding {
+			appPadding = app.paddingAppName
+		}
+	}
+
+	for _, appConfig := range cfg.Apps {
+		if _, ok := applications[appConfig.Name]; ok {
+			continue
+		}
+
+		app, err := newApplication(appConfig, appPadding)
+		if err != nil {
+			gomonLog(err.Error(), true)
+			continue
+		}
+		applications[appConfig.Name] = app
+
+		startApplication(w.ctx, w.wg, app)
+	}
+
+	if err := w.prepareWatcher(w.fsWatcher); err != nil {
+		gomonLog("unable to watch the directories of the new config: "+err.Error(), true)
+	}
+}
+
+// isConfigSource reports whether the file is one of the config files.
+func isConfigSource(file string) bool {
+	for _, source := range cfgSources {
+		if filepath.Clean(source) == filepath.Clean(file) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,265 @@
+package run
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/expectedsh/gomon/pkg/utils"
+)
+
+// scheduler limits the number of builds running at once and shares the builds
+// of the applications with the same path and build options.
+type scheduler struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	running int
+	queued  int
+	// builds are the queued and running builds by key
+	builds map[string]*sharedBuild
+}
+
+type sharedBuild struct {
+	app *application
+	// bin is in a directory of the scheduler, the applications link it to
+	// their own binary: the application starting the build can cancel it and
+	// build again while another one still waits for it.
+	bin string
+	// started is zero while the build is queued
+	started time.Time
+	done    chan struct{}
+	err     error
+	// refs is the number of applications waiting for the build, it is
+	// cancelled when there is none
+	refs   int
+	cancel context.CancelFunc
+}
+
+// buildCount numbers the directories of the builds.
+var buildCount uint64
+
+var builds = newScheduler()
+
+func newScheduler() *scheduler {
+	s := &scheduler{
+		builds: map[string]*sharedBuild{},
+	}
+	s.cond = sync.NewCond(&s.mutex)
+
+	return s
+}
+
+// build builds the binary of the application at bin once a worker is
+// available. A build of the same target queued or started after since, the
+// time of the change to build, is shared instead of building again. The build
+// is cancelled with ctx when no other application waits for it.
+func (s *scheduler) build(ctx context.Context, a *application, since time.Time, bin string) error {
+	key := a.buildKey()
+
+	s.mutex.Lock()
+
+	b, ok := s.builds[key]
+	if ok && b.refs > 0 && (b.started.IsZero() || !b.started.Before(since)) {
+		b.refs++
+		s.mutex.Unlock()
+
+		if b.app != a {
+			a.log("Sharing the build of "+b.app.config.Name, false, "BUILDER")
+		}
+	} else {
+		buildCtx, cancel := context.WithCancel(context.Background())
+		b = &sharedBuild{app: a, done: make(chan struct{}), refs: 1, cancel: cancel}
+		if bin != "" {
+			id := atomic.AddUint64(&buildCount, 1)
+			b.bin = filepath.Join(utils.GetGomonBuilds(cfgHash), "shared", fmt.Sprintf("%s.%d", key, id), a.config.Name)
+		}
+		s.builds[key] = b
+
+		s.mutex.Unlock()
+
+		go s.run(buildCtx, key, b)
+	}
+
+	if err := s.wait(ctx, b); err != nil {
+		return err
+	}
+	defer s.release(b)
+
+	if bin == "" {
+		return nil
+	}
+
+	return linkBinary(b.bin, bin)
+}
+
+// run builds once a worker is available, unless the build is cancelled while
+// it is queued.
+func (s *scheduler) run(ctx context.Context, key string, b *sharedBuild) {
+	defer close(b.done)
+	defer b.cancel()
+
+	s.mutex.Lock()
+
+	if s.running >= buildWorkers() {
+		s.queued++
+		b.app.log(fmt.Sprintf("Waiting for a build worker, %d running, %d queued", s.running, s.queued), false, "BUILDER")
+
+		for s.running >= buildWorkers() && ctx.Err() == nil {
+			s.cond.Wait()
+		}
+		s.queued--
+	}
+
+	if ctx.Err() != nil {
+		if s.builds[key] == b {
+			delete(s.builds, key)
+		}
+		s.mutex.Unlock()
+
+		b.err = ctx.Err()
+		return
+	}
+
+	s.running++
+	b.started = time.Now()
+	s.mutex.Unlock()
+
+	err := b.prepare()
+	if err == nil {
+		err = b.app.compile(ctx, b.bin)
+	}
+
+	s.mutex.Lock()
+	s.running--
+	if s.builds[key] == b {
+		delete(s.builds, key)
+	}
+	s.cond.Broadcast()
+	s.mutex.Unlock()
+
+	b.err = err
+}
+
+// prepare creates the directory of the binary.
+func (b *sharedBuild) prepare() error {
+	if b.bin == "" {
+		return nil
+	}
+
+	return os.MkdirAll(filepath.Dir(b.bin), os.ModePerm)
+}
+
+// wait waits for the end of the build, or for ctx to be cancelled. A failed or
+// cancelled build is released, a successful one must be released once its
+// binary is linked.
+func (s *scheduler) wait(ctx context.Context, b *sharedBuild) error {
+	select {
+	case <-b.done:
+		if b.err != nil {
+			s.release(b)
+		}
+		return b.err
+	case <-ctx.Done():
+		// the build is cancelled and stopped when no application waits
+		// for it anymore
+		s.release(b)
+		return ctx.Err()
+	}
+}
+
+// release stops waiting for the build. The last application cancels it if it
+// is still running, and removes its binary.
+func (s *scheduler) release(b *sharedBuild) {
+	s.mutex.Lock()
+	b.refs--
+	last := b.refs == 0
+	if last {
+		b.cancel()
+		s.cond.Broadcast()
+	}
+	s.mutex.Unlock()
+
+	if !last {
+		return
+	}
+
+	// the worker is released once the go command is killed
+	<-b.done
+
+	if b.bin != "" {
+		_ = os.RemoveAll(filepath.Dir(b.bin))
+	}
+}
+
+// buildWorkers returns the maximum number of builds running at once.
+func buildWorkers() int {
+	if workers := currentSettings().buildWorkers; workers > 0 {
+		return workers
+	}
+
+	return runtime.NumCPU()
+}
+
+// buildKey identifies the binary built for the application, the applications
+// with the same key can share their build.
+func (a *application) buildKey() string {
+	if a.config.IsCommand() {
+		// the build command runs with the environment of the application, a
+		// build whose environment can not be loaded is not shared
+		parts := []string{a.config.Cwd, a.config.BuildCommand}
+		if env, err := a.environment(); err == nil {
+			parts = append(parts, env...)
+		} else {
+			parts = append(parts, "app="+a.config.Name)
+		}
+
+		sum := md5.Sum([]byte(strings.Join(parts, "\x00")))
+		return hex.EncodeToString(sum[:])
+	}
+
+	parts := []string{a.moduleDir, a.config.BuildPath(a.moduleDir)}
+	parts = append(parts, a.config.Build.Args("", "")...)
+	parts = append(parts, a.config.Build.Environ()...)
+
+	sum := md5.Sum([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// linkBinary makes the binary built for an application available at the path
+// of another one.
+func linkBinary(src, dst string) error {
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Link(src, dst); err == nil {
+		return nil
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
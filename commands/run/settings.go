@@ -0,0 +1,105 @@
+package run
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/expectedsh/gomon/pkg/config"
+)
+
+// runSettings are the flags merged with the settings of the config. A reload
+// of the config stores a new value, the goroutines of the applications read
+// them with currentSettings and never see a partial update.
+type runSettings struct {
+	directories    []string
+	envFiles       []string
+	env            map[string]string
+	watchTimeout   time.Duration
+	killTimeout    time.Duration
+	colors         bool
+	pid            bool
+	ignoreComments bool
+	buildWorkers   int
+	goGenerate     bool
+}
+
+var (
+	// runFlags are only read after the start, the flags keep the values of
+	// the command line or their default value.
+	runFlags *pflag.FlagSet
+	settings atomic.Value
+)
+
+// initSettings keeps the flags, it must be called before applySettings.
+func initSettings(flags *pflag.FlagSet) {
+	runFlags = flags
+	settings.Store(flagSettings())
+}
+
+// flagSettings returns the settings of the command line.
+func flagSettings() runSettings {
+	return runSettings{
+		directories:    fDirectories,
+		envFiles:       fEnvFiles,
+		watchTimeout:   fWatchTimeout,
+		killTimeout:    fKillTimeout,
+		colors:         fColors,
+		pid:            fPid,
+		ignoreComments: fIgnoreComments,
+		buildWorkers:   fBuildWorkers,
+		goGenerate:     fGoGenerate,
+	}
+}
+
+// currentSettings returns the settings in use.
+func currentSettings() runSettings {
+	s, _ := settings.Load().(runSettings)
+	return s
+}
+
+// applySettings uses the settings of the config for the flags that are not
+// given in the command line.
+func applySettings(cfg config.Settings) {
+	s := flagSettings()
+	s.env = cfg.Env
+
+	if !runFlags.Changed("directories") && cfg.Directories != nil {
+		s.directories = cfg.Directories
+	}
+
+	if !runFlags.Changed("env-file") && cfg.EnvFile != nil {
+		s.envFiles = cfg.EnvFile
+	}
+
+	if !runFlags.Changed("watch-timeout") && cfg.WatchTimeout != 0 {
+		s.watchTimeout = cfg.WatchTimeout
+	}
+
+	if !runFlags.Changed("kill-timeout") && cfg.KillTimeout != 0 {
+		s.killTimeout = cfg.KillTimeout
+	}
+
+	if !runFlags.Changed("colors") && cfg.Colors != nil {
+		s.colors = *cfg.Colors
+	}
+
+	if !runFlags.Changed("pid") && cfg.Pid != nil {
+		s.pid = *cfg.Pid
+	}
+
+	if !runFlags.Changed("ignore-comments") && cfg.IgnoreComments != nil {
+		s.ignoreComments = *cfg.IgnoreComments
+	}
+
+	if !runFlags.Changed("build-workers") && cfg.BuildWorkers != 0 {
+		s.buildWorkers = cfg.BuildWorkers
+	}
+
+	if !runFlags.Changed("go-generate") && cfg.GoGenerate != nil {
+		s.goGenerate = *cfg.GoGenerate
+	}
+
+	settings.Store(s)
+}
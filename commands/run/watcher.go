@@ -3,6 +3,8 @@ package run
 import (
 	"context"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -10,23 +12,41 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/pkg/errors"
 
+	"github.com/expectedsh/gomon/pkg/contents"
+	"github.com/expectedsh/gomon/pkg/gomodule"
 	"github.com/expectedsh/gomon/pkg/utils"
 )
 
 type watcher struct {
-	ctx context.Context
+	ctx       context.Context
+	wg        *sync.WaitGroup
+	fsWatcher *fsnotify.Watcher
 
 	mutex         sync.Mutex
 	lastEvent     time.Time
 	appsToRestart map[string]bool
+	// changedFiles are compared with their previous content once the events
+	// settled, a file is often truncated before being written.
+	changedFiles  map[string]bool
+	configChanged bool
+	// patterns are rebuilt with the applications, in prepareWatcher.
+	patterns *watchPatterns
+	// watched are the directories added to the watcher, the roots given to
+	// it are never removed.
+	watched map[string]bool
+	roots   map[string]bool
 }
 
-func newWatcher(ctx context.Context) *watcher {
+func newWatcher(ctx context.Context, wg *sync.WaitGroup) *watcher {
 	return &watcher{
 		ctx:           ctx,
+		wg:            wg,
 		mutex:         sync.Mutex{},
 		lastEvent:     time.Time{},
 		appsToRestart: make(map[string]bool),
+		changedFiles:  make(map[string]bool),
+		watched:       make(map[string]bool),
+		roots:         make(map[string]bool),
 	}
 }
 
@@ -36,6 +56,8 @@ func (w *watcher) watchForRestarts() error {
 		return errors.Wrap(err, "unable to create watcher")
 	}
 
+	w.fsWatcher = watcher
+
 	if err := w.prepareWatcher(watcher); err != nil {
 		return errors.Wrap(err, "unable to prepare watcher with files and directories")
 	}
@@ -58,6 +80,8 @@ func (w *watcher) watchForRestarts() error {
 					continue
 				}
 
+				event.Name = filepath.Clean(event.Name)
+
 				w.processWatchedEvent(watcher, event)
 			case <-watcher.Errors:
 				continue
@@ -69,7 +93,42 @@ func (w *watcher) watchForRestarts() error {
 }
 
 func (w *watcher) prepareWatcher(watcher *fsnotify.Watcher) error {
-	for _, directory := range fDirectories {
+	w.patterns = newWatchPatterns()
+
+	roots := append(append([]string{}, currentSettings().directories...), generatorRoots()...)
+	directories := []string{}
+	for _, source := range cfgSources {
+		directories = append(directories, filepath.Dir(source))
+	}
+	for _, app := range applications {
+		roots = append(roots, app.watchRoots()...)
+		directories = append(directories, app.watchDirectories()...)
+	}
+
+	registered := map[string]bool{}
+
+	if err := w.addDirectories(watcher, directories, registered); err != nil {
+		return err
+	}
+
+	return w.addRoots(watcher, roots, registered)
+}
+
+// watchApplication registers the directories of an application whose graph
+// changed.
+func (w *watcher) watchApplication(watcher *fsnotify.Watcher, app *application) error {
+	registered := map[string]bool{}
+
+	if err := w.addDirectories(watcher, app.watchDirectories(), registered); err != nil {
+		return err
+	}
+
+	return w.addRoots(watcher, app.watchRoots(), registered)
+}
+
+// addRoots registers the directories with all their sub directories.
+func (w *watcher) addRoots(watcher *fsnotify.Watcher, roots []string, registered map[string]bool) error {
+	for _, directory := range roots {
 		if _, err := os.Lstat(directory); err != nil {
 			if os.IsNotExist(err) {
 				continue
@@ -77,29 +136,105 @@ func (w *watcher) prepareWatcher(watcher *fsnotify.Watcher) error {
 			return err
 		}
 
+		w.roots[filepath.Clean(directory)] = true
+
 		subDirs := []string{directory}
 		utils.GetSubDirectories(directory, &subDirs)
 
 		for _, subDir := range subDirs {
+			subDir = filepath.Clean(subDir)
+			if registered[subDir] || isHidden(directory, subDir) || w.isExcludedByAll(subDir) {
+				continue
+			}
+
 			if err := watcher.Add(subDir); err != nil {
 				return err
 			}
+			registered[subDir] = true
+			w.watched[subDir] = true
+		}
+	}
+
+	return nil
+}
+
+// addDirectories registers the directories without their sub directories.
+func (w *watcher) addDirectories(watcher *fsnotify.Watcher, directories []string, registered map[string]bool) error {
+	for _, directory := range directories {
+		directory = filepath.Clean(directory)
+		if registered[directory] || w.isExcludedByAll(directory) {
+			continue
+		}
+
+		if err := watcher.Add(directory); err != nil && !os.IsNotExist(err) {
+			return err
 		}
+		registered[directory] = true
+		w.watched[directory] = true
+		w.roots[directory] = true
 	}
 
 	return nil
 }
 
+// isExcludedByAll reports whether no application is interested in this path,
+// in this case there is no need to watch it.
+func (w *watcher) isExcludedByAll(path string) bool {
+	for _, app := range applications {
+		if !app.isExcluded(path) {
+			return false
+		}
+	}
+
+	return len(applications) > 0
+}
+
+// isHidden reports whether a directory found under root is hidden (.git,
+// .idea, ...), the root itself is never considered hidden.
+func isHidden(root, dir string) bool {
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == "." {
+		return false
+	}
+
+	for _, segment := range strings.Split(filepath.ToSlash(rel), "/") {
+		if strings.HasPrefix(segment, ".") {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (w *watcher) handleRestarts() {
 	for {
 		w.mutex.Lock()
-		if !w.lastEvent.IsZero() && time.Since(w.lastEvent) >= fWatchTimeout {
+		if !w.lastEvent.IsZero() && time.Since(w.lastEvent) >= currentSettings().watchTimeout {
+			// changedAt is the time of the last change of the batch, the
+			// files written by the generators are part of it
+			changedAt := w.lastEvent
 			w.lastEvent = time.Time{}
-			for app := range w.appsToRestart {
-				applications[app].log("", false, "")
-				applications[app].log("Restarting ...", false, "GOMON")
-				applications[app].log("", false, "")
-				applications[app].restart <- true
+
+			if w.configChanged {
+				w.configChanged = false
+				w.reloadConfig()
+			}
+
+			w.processChangedFiles()
+			if w.lastEvent.After(changedAt) {
+				changedAt = w.lastEvent
+			}
+
+			for name := range w.appsToRestart {
+				app, ok := applications[name]
+				if !ok {
+					continue
+				}
+
+				app.log("", false, "")
+				app.log("Restarting ...", false, "GOMON")
+				app.log("", false, "")
+				app.requestRestart(changedAt)
 			}
 			w.appsToRestart = map[string]bool{}
 		}
@@ -116,37 +251,253 @@ func (w *watcher) processWatchedEvent(watcher *fsnotify.Watcher, ev fsnotify.Eve
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
-	if ev.Op&fsnotify.Rename == fsnotify.Rename {
-		for _, app := range applications {
-			delete(app.files, ev.Name)
+	if isConfigSource(ev.Name) {
+		if ev.Op&(fsnotify.Write|fsnotify.Create) != 0 {
+			w.lastEvent = time.Now()
+			w.configChanged = true
 		}
+		return
+	}
 
-		watcher.Remove(ev.Name)
+	if ev.Op&(fsnotify.Rename|fsnotify.Remove) != 0 {
+		// an editor saving by moving the old file aside writes it again
+		// right after, the removal is only known once the events settled
+		w.fileWritten(ev.Name)
+
+		w.directoryRemoved(watcher, ev.Name)
 		return
 	}
 
-	if ev.Op&fsnotify.Remove == fsnotify.Remove {
-		for _, app := range applications {
-			delete(app.files, ev.Name)
+	if ev.Op&fsnotify.Create == fsnotify.Create && !w.isExcludedByAll(ev.Name) {
+		if info, err := os.Stat(ev.Name); err == nil && info.IsDir() {
+			w.directoryCreated(watcher, ev.Name)
+			return
 		}
 
-		watcher.Remove(ev.Name)
+		// a file saved by renaming a temporary file has no write event
+		w.fileWritten(ev.Name)
 		return
 	}
 
-	if ev.Op&fsnotify.Create == fsnotify.Create {
-		watcher.Add(ev.Name)
+	if ev.Op&fsnotify.Write == fsnotify.Write {
+		w.fileWritten(ev.Name)
+		return
 	}
+}
 
-	if ev.Op&fsnotify.Write == fsnotify.Write {
-		w.lastEvent = time.Now()
-		for _, app := range applications {
-			if _, ok := app.files[ev.Name]; ok {
-				app.updateFiles(ev.Name)
-				w.appsToRestart[app.config.Name] = true
+// directoryRemoved stops watching a removed directory added by gomon, the
+// files are watched with their directory. A root, such as the working
+// directory, is never removed.
+func (w *watcher) directoryRemoved(watcher *fsnotify.Watcher, path string) {
+	path = filepath.Clean(path)
+	if !w.watched[path] || w.roots[path] || path == "." {
+		return
+	}
+
+	_ = watcher.Remove(path)
+	delete(w.watched, path)
+}
+
+// fileRemoved drops the file from the graphs, the applications built with it
+// are restarted without it. The watcher mutex must be held.
+func (w *watcher) fileRemoved(file string) {
+	for _, name := range index.Apps(file) {
+		app, ok := applications[name]
+		if !ok || app.isExcluded(file) {
+			continue
+		}
+
+		if _, err := app.refreshGraph(file, true); err != nil {
+			app.log(err.Error(), true, "GOMON")
+		}
+
+		w.appsToRestart[name] = true
+	}
+
+	index.RemoveFile(file)
+	hashes.Remove(file)
+}
+
+// directoryCreated watches a new directory, the files created in it before it
+// was watched are processed as changes.
+func (w *watcher) directoryCreated(watcher *fsnotify.Watcher, directory string) {
+	if err := w.addRoots(watcher, []string{directory}, map[string]bool{}); err != nil {
+		gomonLog(err.Error(), true)
+	}
+
+	_ = filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			w.fileWritten(path)
+		}
+		return nil
+	})
+}
+
+// fileWritten delays the processing of a written or removed file until the
+// events settled.
+func (w *watcher) fileWritten(file string) {
+	w.lastEvent = time.Now()
+	w.changedFiles[file] = true
+}
+
+// processChangedFiles finds the applications to restart after the changes.
+// A file that no longer exists is removed, a file written again with the same
+// content is ignored. The generators using the changed files run first, each
+// at most once, with the mutex released; the files they write are then
+// processed the same way. The watcher mutex must be held.
+func (w *watcher) processChangedFiles() {
+	ran := map[string]bool{}
+
+	for len(w.changedFiles) > 0 {
+		var changed, removed []string
+		for file := range w.changedFiles {
+			if _, err := os.Lstat(file); os.IsNotExist(err) {
+				removed = append(removed, file)
+			} else if w.hasChanged(file) {
+				changed = append(changed, file)
 			}
 		}
+		sort.Strings(changed)
+		sort.Strings(removed)
+		w.changedFiles = map[string]bool{}
+
+		inputs := append(append([]string{}, changed...), removed...)
+		if generators, generations := generatorsFor(inputs, ran); len(generators) > 0 || len(generations) > 0 {
+			w.mutex.Unlock()
+			runGenerators(w.ctx, generators, generations)
+			w.mutex.Lock()
+		}
+
+		for _, file := range removed {
+			w.fileRemoved(file)
+		}
 
+		for _, file := range changed {
+			w.fileChanged(w.fsWatcher, file)
+		}
+	}
+}
+
+// hasChanged reports whether the content of the file changed, the writes that
+// did not change it are ignored.
+func (w *watcher) hasChanged(file string) bool {
+	switch hashes.Update(file) {
+	case contents.Unchanged:
+		return false
+	case contents.CommentsOnly:
+		if currentSettings().ignoreComments {
+			w.changeIgnored(file)
+			return false
+		}
+	}
+
+	return true
+}
+
+// fileChanged finds the applications to restart after a change on the file.
+// The watcher mutex must be held.
+func (w *watcher) fileChanged(watcher *fsnotify.Watcher, file string) {
+	if w.moduleFileChanged(watcher, file) {
 		return
 	}
+
+	for _, name := range w.candidates(file) {
+		app, ok := applications[name]
+		if !ok {
+			continue
+		}
+
+		// a file built into the application can change its imports, another
+		// one can be added to one of its packages
+		if app.isImported(file) {
+			if !app.isWatched(file) {
+				continue
+			}
+			w.refreshGraph(watcher, app, file)
+		} else if !w.refreshGraph(watcher, app, file) && !app.isWatched(file) {
+			continue
+		}
+
+		w.appsToRestart[name] = true
+	}
+}
+
+// candidates returns the sorted names of the applications that can be affected
+// by a change on the file, found in the index and in the patterns.
+func (w *watcher) candidates(file string) []string {
+	found := map[string]bool{}
+	for _, name := range index.Candidates(file) {
+		found[name] = true
+	}
+	w.patterns.candidates(file, found)
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// moduleFileChanged restarts the applications whose dependencies changed after
+// a change on a go.mod, go.sum, go.work or vendor/modules.txt. When the
+// dependencies can not be listed, the application is restarted to report the
+// error of the build. It reports whether the file is a module file.
+func (w *watcher) moduleFileChanged(watcher *fsnotify.Watcher, file string) bool {
+	if !gomodule.IsFile(file) {
+		return false
+	}
+
+	found := false
+	for name, app := range applications {
+		if !app.isModuleFile(file) {
+			continue
+		}
+		found = true
+
+		changed, err := app.reloadDependencies()
+		if err != nil {
+			app.log(err.Error(), true, "GOMON")
+		}
+
+		if !changed {
+			app.log(file+" changed without changing the dependencies", false, "GOMON")
+			continue
+		}
+
+		if err := w.watchApplication(watcher, app); err != nil {
+			app.log(err.Error(), true, "GOMON")
+		}
+		w.appsToRestart[name] = true
+	}
+
+	return found
+}
+
+// changeIgnored reports the applications that are not restarted because only
+// the comments of the file changed.
+func (w *watcher) changeIgnored(file string) {
+	for _, name := range w.candidates(file) {
+		if app, ok := applications[name]; ok && app.isWatched(file) {
+			app.log(file+": change ignored (comments only)", false, "GOMON")
+		}
+	}
+}
+
+// refreshGraph updates the graph of the application after a change on the
+// file and watches the directories of the new packages.
+func (w *watcher) refreshGraph(watcher *fsnotify.Watcher, app *application, file string) bool {
+	updated, err := app.refreshGraph(file, false)
+	if err != nil {
+		app.log(err.Error(), true, "GOMON")
+	}
+
+	if updated {
+		if err := w.watchApplication(watcher, app); err != nil {
+			app.log(err.Error(), true, "GOMON")
+		}
+	}
+
+	return updated
 }
@@ -0,0 +1,38 @@
+package validate
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/expectedsh/gomon/pkg/config"
+)
+
+var Command = &cobra.Command{
+	Use:          "validate",
+	Short:        "Check the config file and report every problem found in it",
+	Example:      "gomon validate",
+	RunE:         run,
+	SilenceUsage: true,
+}
+
+func run(c *cobra.Command, _ []string) error {
+	files, profile, err := config.FromFlags(c.Flags())
+	if err != nil {
+		return err
+	}
+
+	cfg, problems, err := config.Validate(files, profile)
+	if err != nil {
+		return err
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+
+	fmt.Printf("%s is valid, %d application(s) described\n", strings.Join(files, ", "), len(cfg.Apps))
+
+	return nil
+}
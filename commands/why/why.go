@@ -0,0 +1,81 @@
+package why
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/expectedsh/gomon/pkg/config"
+	"github.com/expectedsh/gomon/pkg/imports"
+)
+
+var Command = &cobra.Command{
+	Use:          "why <file|package>",
+	Short:        "Explain which applications depend on a file or a package, and through which imports",
+	Example:      "gomon why pkg/greet/greet.go",
+	Args:         cobra.ExactArgs(1),
+	RunE:         run,
+	SilenceUsage: true,
+}
+
+func run(c *cobra.Command, args []string) error {
+	cfg, err := config.LoadFromFlags(c.Flags())
+	if err != nil {
+		return err
+	}
+
+	target := args[0]
+
+	var independent []string
+	for _, app := range cfg.Apps {
+		graph, err := imports.LoadApplication(app)
+		if err != nil {
+			return errors.Wrap(err, "unable to load the packages of "+app.Name)
+		}
+
+		importPath, ok := graph.Resolve(target)
+		if !ok {
+			if app.MatchesWatchPatterns(filepath.Clean(target)) && !app.IsExcluded(filepath.Clean(target)) {
+				fmt.Printf("%s -> %s (watch patterns)\n", app.Name, target)
+				continue
+			}
+
+			independent = append(independent, app.Name)
+			continue
+		}
+
+		fmt.Println(strings.Join(chain(app, graph, importPath, target), " -> "))
+	}
+
+	if len(independent) == len(cfg.Apps) {
+		fmt.Printf("No application depends on %s\n", target)
+	} else if len(independent) > 0 {
+		fmt.Printf("Not depending on %s: %s\n", target, strings.Join(independent, ", "))
+	}
+
+	return nil
+}
+
+// chain returns the names to print from the application to the target: the
+// main file of the application, the imported packages, then the target when it
+// is a file.
+func chain(app config.Application, graph *imports.Graph, importPath, target string) []string {
+	names := []string{app.Name, app.Path}
+
+	packages := graph.Chain(importPath)
+	if len(packages) > 0 {
+		// the main package is the path of the application
+		packages = packages[1:]
+	}
+	names = append(names, packages...)
+
+	if info, err := os.Stat(target); err == nil && !info.IsDir() && filepath.Clean(target) != filepath.Clean(app.Path) {
+		names = append(names, target)
+	}
+
+	return names
+}
@@ -4,7 +4,8 @@ go 1.14
 
 require (
 	github.com/fsnotify/fsnotify v1.4.7
-	github.com/ghodss/yaml v1.0.0
 	github.com/pkg/errors v0.8.0
 	github.com/spf13/cobra v1.0.0
+	github.com/spf13/pflag v1.0.3
+	gopkg.in/yaml.v3 v3.0.1
 )
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"errors"
+	"os"
+
 	"github.com/spf13/cobra"
 
+	"github.com/expectedsh/gomon/commands/affected"
+	"github.com/expectedsh/gomon/commands/config"
+	"github.com/expectedsh/gomon/commands/graph"
+	"github.com/expectedsh/gomon/commands/init_config"
 	"github.com/expectedsh/gomon/commands/older_pids"
 	"github.com/expectedsh/gomon/commands/run"
+	"github.com/expectedsh/gomon/commands/validate"
+	"github.com/expectedsh/gomon/commands/why"
 )
 
 var rootCmd = &cobra.Command{
@@ -15,15 +24,30 @@ var rootCmd = &cobra.Command{
 }
 
 func main() {
-	_ = rootCmd.Execute()
+	if err := rootCmd.Execute(); errors.Is(err, affected.ErrNoneAffected) {
+		os.Exit(2)
+	} else if err != nil {
+		os.Exit(1)
+	}
 }
 
 func init() {
-	rootCmd.Flags().String(
+	rootCmd.PersistentFlags().StringArray(
 		"config",
-		"./.gomon.yaml",
-		"the location of the config file that describe what to run")
+		[]string{"./.gomon.yaml"},
+		"the location of the config file that describe what to run, repeat it to merge several files in order")
+
+	rootCmd.PersistentFlags().String(
+		"profile",
+		"",
+		"only use the applications of this profile")
 
 	rootCmd.AddCommand(run.Command)
 	rootCmd.AddCommand(older_pids.Command)
+	rootCmd.AddCommand(validate.Command)
+	rootCmd.AddCommand(config.Command)
+	rootCmd.AddCommand(init_config.Command)
+	rootCmd.AddCommand(graph.Command)
+	rootCmd.AddCommand(why.Command)
+	rootCmd.AddCommand(affected.Command)
 }
@@ -86,3 +86,20 @@ func (c Color) String() string {
 
 	return ""
 }
+
+// Names is the list of the colors that can be used in the config file.
+var Names = []Color{
+	Red, Green, Yellow, Blue, Purple, Cyan, Gray,
+	RedLight, GreenLight, YellowLight, BlueLight, PurpleLight, CyanLight,
+	White,
+}
+
+func (c Color) IsValid() bool {
+	for _, name := range Names {
+		if c == name {
+			return true
+		}
+	}
+
+	return false
+}
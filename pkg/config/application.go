@@ -0,0 +1,83 @@
+package config
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/expectedsh/gomon/pkg/gomodule"
+	"github.com/expectedsh/gomon/pkg/utils"
+)
+
+// IsCommand reports whether the application is a shell command instead of a go
+// main package.
+func (a Application) IsCommand() bool {
+	return a.Command != ""
+}
+
+// ModuleDir returns the directory of the go.mod used to build the application,
+// by default the closest one above its path.
+func (a Application) ModuleDir() (string, error) {
+	moduleDir := a.Module
+	if moduleDir == "" {
+		dir, err := gomodule.FindDir(a.Path)
+		if err != nil {
+			return "", err
+		}
+		moduleDir = dir
+	}
+
+	if _, err := gomodule.GetNameFromDir(moduleDir); err != nil {
+		return "", errors.Wrap(err, "unable to get the module of "+a.Name)
+	}
+
+	return filepath.Clean(moduleDir), nil
+}
+
+// BuildPath returns the path of the main package relative to the module
+// directory, the go commands run in this directory.
+func (a Application) BuildPath(moduleDir string) string {
+	rel, err := filepath.Rel(moduleDir, a.Path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return a.Path
+	}
+
+	return "." + string(filepath.Separator) + rel
+}
+
+// IsExcluded reports whether the file or directory matches one of the
+// directories_to_exclude or files_to_exclude patterns.
+func (a Application) IsExcluded(file string) bool {
+	for _, pattern := range a.DirectoriesToExclude {
+		if utils.MatchGlobDir(pattern, file) {
+			return true
+		}
+	}
+
+	for _, pattern := range a.FilesToExclude {
+		if utils.MatchGlob(pattern, file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchesWatchPatterns reports whether the file matches one of the
+// directories_to_watch or files_to_watch patterns.
+func (a Application) MatchesWatchPatterns(file string) bool {
+	for _, pattern := range a.DirectoriesToWatch {
+		if utils.MatchGlobDir(pattern, file) {
+			return true
+		}
+	}
+
+	for _, pattern := range a.FilesToWatch {
+		if utils.MatchGlob(pattern, file) {
+			return true
+		}
+	}
+
+	return false
+}
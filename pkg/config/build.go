@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Build struct {
+	Flags    []string          `yaml:"flags,omitempty"`
+	Tags     []string          `yaml:"tags,omitempty"`
+	Ldflags  string            `yaml:"ldflags,omitempty"`
+	Gcflags  string            `yaml:"gcflags,omitempty"`
+	Trimpath bool              `yaml:"trimpath,omitempty"`
+	Race     bool              `yaml:"race,omitempty"`
+	Env      map[string]string `yaml:"env,omitempty"`
+}
+
+// Args returns the arguments given to the go command to build the package at
+// path into the binary bin.
+func (b Build) Args(bin, path string) []string {
+	args := []string{"build", "-o", bin}
+	args = append(args, b.Flags...)
+
+	if len(b.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(b.Tags, ","))
+	}
+
+	if b.Ldflags != "" {
+		args = append(args, "-ldflags", b.Ldflags)
+	}
+
+	if b.Gcflags != "" {
+		args = append(args, "-gcflags", b.Gcflags)
+	}
+
+	if b.Trimpath {
+		args = append(args, "-trimpath")
+	}
+
+	if b.Race {
+		args = append(args, "-race")
+	}
+
+	return append(args, path)
+}
+
+// Environ returns the environment variables only given to the build command,
+// sorted to be displayed in a stable way.
+func (b Build) Environ() []string {
+	var env []string
+	for k, v := range b.Env {
+		env = append(env, fmt.Sprintf("%s=%s", k, v))
+	}
+
+	sort.Strings(env)
+
+	return env
+}
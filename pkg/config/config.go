@@ -0,0 +1,102 @@
+package config
+
+import (
+	"time"
+
+	"github.com/expectedsh/gomon/pkg/colors"
+)
+
+// Config is the result of the config files merged together, restricted to the
+// applications of the selected profile.
+type Config struct {
+	// Hash identifies the config files and the profile, it does not depend on
+	// the content of the files so it is stable while they are edited.
+	Hash  string
+	Files []string
+	// Sources are all the files read, including the extended ones.
+	Sources    []string
+	Profile    string
+	Settings   Settings
+	Apps       []Application
+	Generators []Generator
+	Profiles   map[string]Profile
+}
+
+// File is the content of a config file. A file can also be a bare list of
+// applications, it is then the same as a file with only apps.
+type File struct {
+	Extends    StringList         `yaml:"extends,omitempty"`
+	Settings   Settings           `yaml:"settings,omitempty"`
+	Apps       []Application      `yaml:"apps,omitempty"`
+	Generators []Generator        `yaml:"generators,omitempty"`
+	Profiles   map[string]Profile `yaml:"profiles,omitempty"`
+}
+
+// Profile is a named set of applications selected with --profile.
+type Profile struct {
+	Apps []string `yaml:"apps,omitempty"`
+}
+
+// Generator runs a command when one of its inputs changes. The applications
+// importing its outputs are then restarted as for any other change.
+type Generator struct {
+	Name string `yaml:"name"`
+	// Inputs and Outputs are glob patterns relative to the gomon working
+	// directory, the outputs never trigger the generator.
+	Inputs  []string `yaml:"inputs"`
+	Command string   `yaml:"command"`
+	Outputs []string `yaml:"outputs,omitempty"`
+	Cwd     string   `yaml:"cwd,omitempty"`
+}
+
+type Application struct {
+	Name string `yaml:"name"`
+	Path string `yaml:"path,omitempty"`
+	// Command is a shell command run instead of a go binary, BuildCommand is
+	// an optional shell command run before it, on every restart.
+	Command      string   `yaml:"command,omitempty"`
+	BuildCommand string   `yaml:"build_command,omitempty"`
+	Args         []string `yaml:"args,omitempty"`
+	// Cwd is the working directory of the process, Module the directory of the
+	// go.mod of the application, by default the closest one above Path.
+	Cwd            string            `yaml:"cwd,omitempty"`
+	Module         string            `yaml:"module,omitempty"`
+	Env            map[string]string `yaml:"env,omitempty"`
+	EnvFile        StringList        `yaml:"env_file,omitempty"`
+	Color          colors.Color      `yaml:"color,omitempty"`
+	MustNotRestart bool              `yaml:"must_not_restart,omitempty"`
+	Build          Build             `yaml:"build,omitempty"`
+
+	// glob patterns, relative to the gomon working directory, a "**" segment
+	// matches any number of directories. Excludes always win over the files
+	// imported by the application and over the patterns to watch.
+
+	DirectoriesToWatch   []string `yaml:"directories_to_watch,omitempty"`
+	DirectoriesToExclude []string `yaml:"directories_to_exclude,omitempty"`
+
+	FilesToWatch   []string `yaml:"files_to_watch,omitempty"`
+	FilesToExclude []string `yaml:"files_to_exclude,omitempty"`
+}
+
+// Settings are the global settings of gomon, a flag given to the command line
+// takes precedence over its setting.
+type Settings struct {
+	Directories  []string      `yaml:"directories,omitempty"`
+	WatchTimeout time.Duration `yaml:"watch_timeout,omitempty"`
+	KillTimeout  time.Duration `yaml:"kill_timeout,omitempty"`
+	Colors       *bool         `yaml:"colors,omitempty"`
+	Pid          *bool         `yaml:"pid,omitempty"`
+	// IgnoreComments ignores the changes on comments and spacing of go files.
+	IgnoreComments *bool `yaml:"ignore_comments,omitempty"`
+	// BuildWorkers is the maximum number of builds running at once.
+	BuildWorkers int `yaml:"build_workers,omitempty"`
+	// GoGenerate runs go generate for the //go:generate directives
+	// referencing a changed file.
+	GoGenerate *bool `yaml:"go_generate,omitempty"`
+
+	// default environment of every application, the env files are loaded
+	// before env and both before the env files of the application.
+
+	Env     map[string]string `yaml:"env,omitempty"`
+	EnvFile StringList        `yaml:"env_file,omitempty"`
+}
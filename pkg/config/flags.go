@@ -0,0 +1,31 @@
+package config
+
+import (
+	"github.com/spf13/pflag"
+)
+
+// FromFlags returns the config files and the profile given to the root
+// command with --config and --profile.
+func FromFlags(flags *pflag.FlagSet) ([]string, string, error) {
+	files, err := flags.GetStringArray("config")
+	if err != nil {
+		return nil, "", err
+	}
+
+	profile, err := flags.GetString("profile")
+	if err != nil {
+		return nil, "", err
+	}
+
+	return files, profile, nil
+}
+
+// LoadFromFlags loads the config described by --config and --profile.
+func LoadFromFlags(flags *pflag.FlagSet) (*Config, error) {
+	files, profile, err := FromFlags(flags)
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(files, profile)
+}
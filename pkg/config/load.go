@@ -0,0 +1,393 @@
+package config
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// StringList accepts either a single string or a list of strings in the
+// config file.
+type StringList []string
+
+func (l *StringList) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = StringList{value.Value}
+		return nil
+	}
+
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+
+	*l = list
+	return nil
+}
+
+// Load reads the config files, merges them in order, validates the result and
+// selects the applications of the profile, if any.
+func Load(files []string, profile string) (*Config, error) {
+	config, problems, err := Validate(files, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
+	}
+
+	return config, nil
+}
+
+// Validate does the same as Load but returns every problem found in the config
+// files instead of failing. The error is only set when a file given in files
+// could not be read.
+func Validate(files []string, profile string) (*Config, Problems, error) {
+	l := &loader{
+		nodes:   map[*yaml.Node]string{},
+		loading: map[string]bool{},
+	}
+
+	var root *yaml.Node
+	for _, file := range files {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		root = l.merge(root, l.load(file, content))
+	}
+
+	hasher := md5.New()
+	for _, file := range files {
+		if abs, err := filepath.Abs(file); err == nil {
+			file = abs
+		}
+		hasher.Write([]byte(file + "\n"))
+	}
+
+	// two runs of different profiles must not share their pids and builds
+	hasher.Write([]byte(profile))
+
+	config := &Config{
+		Hash:    hex.EncodeToString(hasher.Sum(nil)),
+		Files:   files,
+		Sources: l.sources,
+		Profile: profile,
+	}
+
+	if root == nil {
+		return config, l.sorted(), nil
+	}
+
+	var decoded File
+	// errors are already reported by file in load
+	_ = root.Decode(&decoded)
+
+	config.Settings = decoded.Settings
+	config.Apps = decoded.Apps
+	config.Generators = decoded.Generators
+	config.Profiles = decoded.Profiles
+
+	l.checkApplications(valueNode(root, "apps"), config.Apps)
+	l.checkGenerators(valueNode(root, "generators"), config.Generators)
+	l.checkProfiles(valueNode(root, "profiles"), config)
+
+	if profile != "" {
+		if selected, ok := config.Profiles[profile]; ok {
+			config.Apps = selected.filter(config.Apps)
+		} else {
+			var names []string
+			for name := range config.Profiles {
+				names = append(names, name)
+			}
+
+			l.problems = append(l.problems, Problem{
+				File:       files[0],
+				Message:    fmt.Sprintf("unknown profile %q", profile),
+				Suggestion: suggest(profile, names),
+			})
+		}
+	}
+
+	return config, l.sorted(), nil
+}
+
+func (p Profile) filter(apps []Application) []Application {
+	selected := map[string]bool{}
+	for _, name := range p.Apps {
+		selected[name] = true
+	}
+
+	var filtered []Application
+	for _, app := range apps {
+		if selected[app.Name] {
+			filtered = append(filtered, app)
+		}
+	}
+
+	return filtered
+}
+
+type loader struct {
+	// nodes keeps the file of every node to report problems after the merge
+	nodes    map[*yaml.Node]string
+	loading  map[string]bool
+	sources  []string
+	problems Problems
+}
+
+// load parses the content of file, reports the problems of this file and
+// returns it as a mapping node, with the files it extends merged in.
+func (l *loader) load(file string, content []byte) *yaml.Node {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		abs = file
+	}
+
+	l.loading[abs] = true
+	defer delete(l.loading, abs)
+
+	l.sources = append(l.sources, file)
+
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		l.reportYamlError(file, err.Error())
+		return nil
+	}
+
+	if len(document.Content) == 0 {
+		return nil
+	}
+
+	root := document.Content[0]
+	l.register(file, root)
+
+	switch root.Kind {
+	case yaml.SequenceNode:
+		l.checkTypes(file, root, reflect.TypeOf([]Application{}))
+
+		return &yaml.Node{
+			Kind: yaml.MappingNode,
+			Tag:  "!!map",
+			Line: root.Line,
+			Content: []*yaml.Node{
+				{Kind: yaml.ScalarNode, Tag: "!!str", Value: "apps", Line: root.Line},
+				root,
+			},
+		}
+	case yaml.MappingNode:
+		l.checkTypes(file, root, reflect.TypeOf(File{}))
+	default:
+		l.report(root, "see the example in the readme", "the config must be a list of applications or a mapping with settings and apps")
+		return nil
+	}
+
+	var extends StringList
+	extendsNode := valueNode(root, "extends")
+	if extendsNode != nil {
+		_ = extendsNode.Decode(&extends)
+	}
+
+	var merged *yaml.Node
+	for _, parent := range extends {
+		if !filepath.IsAbs(parent) {
+			parent = filepath.Join(filepath.Dir(file), parent)
+		}
+
+		if abs, err := filepath.Abs(parent); err == nil && l.loading[abs] {
+			l.report(extendsNode, "remove one of the extends", "%q is already being loaded, extends must not loop", parent)
+			continue
+		}
+
+		parentContent, err := ioutil.ReadFile(parent)
+		if err != nil {
+			l.report(extendsNode, "", "unable to read %q: %s", parent, err)
+			continue
+		}
+
+		merged = l.merge(merged, l.load(parent, parentContent))
+	}
+
+	return l.merge(merged, l.withoutKey(root, "extends"))
+}
+
+// register records the file of the node and of all its children.
+func (l *loader) register(file string, node *yaml.Node) {
+	l.nodes[node] = file
+	for _, child := range node.Content {
+		l.register(file, child)
+	}
+}
+
+// checkTypes reports the unknown keys and the values that can not be decoded
+// in the expected type.
+func (l *loader) checkTypes(file string, node *yaml.Node, t reflect.Type) {
+	l.checkKeys(node, t)
+
+	if err := node.Decode(reflect.New(t).Interface()); err != nil {
+		if typeErr, ok := err.(*yaml.TypeError); ok {
+			for _, message := range typeErr.Errors {
+				l.reportYamlError(file, message)
+			}
+		} else {
+			l.reportYamlError(file, err.Error())
+		}
+	}
+}
+
+// merge returns the overlay mapping merged on top of base. Applications and
+// generators are merged by name, mappings are merged recursively and any other
+// value of the overlay replaces the one of base.
+func (l *loader) merge(base, overlay *yaml.Node) *yaml.Node {
+	if base == nil {
+		return overlay
+	}
+
+	if overlay == nil {
+		return base
+	}
+
+	merged := l.mergeNodes(base, overlay)
+
+	for key, kind := range map[string]string{"apps": "application", "generators": "generator"} {
+		baseList, overlayList := valueNode(base, key), valueNode(overlay, key)
+		if baseList != nil && overlayList != nil && baseList.Kind == yaml.SequenceNode && overlayList.Kind == yaml.SequenceNode {
+			l.checkDuplicateNames(overlayList, kind)
+			setValue(merged, key, l.mergeByName(baseList, overlayList))
+		}
+	}
+
+	return merged
+}
+
+// checkDuplicateNames reports the names defined twice in a list of a file
+// merged on top of another one, they would be merged silently. The lists of a
+// single file are checked once decoded.
+func (l *loader) checkDuplicateNames(list *yaml.Node, kind string) {
+	names := map[string]*yaml.Node{}
+
+	for _, item := range list.Content {
+		name := valueNode(item, "name")
+		if name == nil || name.Value == "" {
+			continue
+		}
+
+		if previous, ok := names[name.Value]; ok {
+			l.report(name, "rename one of them",
+				"duplicate %s name %q, already defined line %d", kind, name.Value, previous.Line)
+			continue
+		}
+		names[name.Value] = name
+	}
+}
+
+func (l *loader) mergeByName(base, overlay *yaml.Node) *yaml.Node {
+	merged := l.copyNode(base)
+	merged.Content = append([]*yaml.Node{}, base.Content...)
+
+	for _, app := range overlay.Content {
+		found := false
+
+		if name := valueNode(app, "name"); name != nil {
+			for i, existing := range merged.Content {
+				if existingName := valueNode(existing, "name"); existingName != nil && existingName.Value == name.Value {
+					merged.Content[i] = l.mergeNodes(existing, app)
+					found = true
+					break
+				}
+			}
+		}
+
+		if !found {
+			merged.Content = append(merged.Content, app)
+		}
+	}
+
+	return merged
+}
+
+func (l *loader) mergeNodes(base, overlay *yaml.Node) *yaml.Node {
+	if base.Kind != yaml.MappingNode || overlay.Kind != yaml.MappingNode {
+		return overlay
+	}
+
+	merged := l.copyNode(base)
+	merged.Content = append([]*yaml.Node{}, base.Content...)
+
+	for i := 0; i+1 < len(overlay.Content); i += 2 {
+		key, value := overlay.Content[i], overlay.Content[i+1]
+
+		if existing := valueNode(merged, key.Value); existing != nil {
+			setValue(merged, key.Value, l.mergeNodes(existing, value))
+		} else {
+			merged.Content = append(merged.Content, key, value)
+		}
+	}
+
+	return merged
+}
+
+func (l *loader) copyNode(node *yaml.Node) *yaml.Node {
+	copied := *node
+	l.nodes[&copied] = l.nodes[node]
+
+	return &copied
+}
+
+func (l *loader) sorted() Problems {
+	sort.SliceStable(l.problems, func(i, j int) bool {
+		if l.problems[i].File != l.problems[j].File {
+			return l.problems[i].File < l.problems[j].File
+		}
+		return l.problems[i].Line < l.problems[j].Line
+	})
+
+	return l.problems
+}
+
+func (l *loader) withoutKey(node *yaml.Node, key string) *yaml.Node {
+	copied := l.copyNode(node)
+	copied.Content = nil
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value != key {
+			copied.Content = append(copied.Content, node.Content[i], node.Content[i+1])
+		}
+	}
+
+	return copied
+}
+
+func setValue(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// valueNode returns the value of the key in a mapping node.
+func valueNode(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+
+	return nil
+}
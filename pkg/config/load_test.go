@@ -0,0 +1,114 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLoadMerge(t *testing.T) {
+	wantApps := []Application{
+		{
+			Name:    "api",
+			Command: "./api",
+			Args:    []string{"--port", "8080"},
+			Env:     map[string]string{"LOG": "debug", "DB": "postgres"},
+		},
+		{Name: "worker", Command: "./worker"},
+		{Name: "front", Command: "npm run dev"},
+	}
+	wantGenerators := []Generator{
+		{Name: "sqlc", Inputs: []string{"db/queries/*.sql"}, Command: "sqlc generate"},
+	}
+
+	tests := []struct {
+		name        string
+		files       []string
+		wantSources []string
+	}{
+		{
+			name:        "files given in order",
+			files:       []string{"testdata/base.yaml", "testdata/local.yaml"},
+			wantSources: []string{"testdata/base.yaml", "testdata/local.yaml"},
+		},
+		{
+			name:        "extends",
+			files:       []string{"testdata/extends.yaml"},
+			wantSources: []string{"testdata/extends.yaml", "testdata/base.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := Load(tt.files, "")
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+
+			// mappings are merged key by key, scalars and lists are replaced
+			if config.Settings.WatchTimeout != time.Second {
+				t.Errorf("WatchTimeout = %s, want 1s", config.Settings.WatchTimeout)
+			}
+			if want := map[string]string{"A": "base", "B": "local"}; !reflect.DeepEqual(config.Settings.Env, want) {
+				t.Errorf("Settings.Env = %v, want %v", config.Settings.Env, want)
+			}
+
+			if !reflect.DeepEqual(config.Apps, wantApps) {
+				t.Errorf("Apps = %+v, want %+v", config.Apps, wantApps)
+			}
+			if !reflect.DeepEqual(config.Generators, wantGenerators) {
+				t.Errorf("Generators = %+v, want %+v", config.Generators, wantGenerators)
+			}
+			if !reflect.DeepEqual(config.Sources, tt.wantSources) {
+				t.Errorf("Sources = %v, want %v", config.Sources, tt.wantSources)
+			}
+		})
+	}
+}
+
+func TestLoadExtendsLoop(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		want Problems
+	}{
+		{
+			name: "extends itself",
+			file: "testdata/self.yaml",
+			want: Problems{{
+				File:       "testdata/self.yaml",
+				Line:       1,
+				Message:    `"testdata/self.yaml" is already being loaded, extends must not loop`,
+				Suggestion: "remove one of the extends",
+			}},
+		},
+		{
+			name: "extends a file extending it",
+			file: "testdata/loop_a.yaml",
+			want: Problems{{
+				File:       "testdata/loop_b.yaml",
+				Line:       1,
+				Message:    `"testdata/loop_a.yaml" is already being loaded, extends must not loop`,
+				Suggestion: "remove one of the extends",
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, problems, err := Validate([]string{tt.file}, "")
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(problems, tt.want) {
+				t.Errorf("Validate() problems = %v, want %v", problems, tt.want)
+			}
+
+			// the loop is cut, the applications are still loaded
+			if len(config.Apps) != 1 || config.Apps[0].Name != "api" {
+				t.Errorf("Apps = %+v, want the api application", config.Apps)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// suggest returns a "did you mean" message with the candidate closest to word.
+func suggest(word string, candidates []string) string {
+	best := closestWord(word, candidates)
+	if best == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("did you mean %q?", best)
+}
+
+// closestWord returns the candidate closest to word, or an empty string if no
+// candidate is close enough.
+func closestWord(word string, candidates []string) string {
+	best, bestDistance := "", -1
+
+	for _, candidate := range candidates {
+		distance := levenshtein(strings.ToLower(word), strings.ToLower(candidate))
+		if bestDistance == -1 || distance < bestDistance || distance == bestDistance && candidate < best {
+			best, bestDistance = candidate, distance
+		}
+	}
+
+	maxDistance := len(word) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	if bestDistance == -1 || bestDistance > maxDistance {
+		return ""
+	}
+
+	return best
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func min(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+
+	return m
+}
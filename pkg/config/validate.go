@@ -0,0 +1,365 @@
+package config
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/expectedsh/gomon/pkg/colors"
+)
+
+// Problem is an error found in a config file.
+type Problem struct {
+	File       string
+	Line       int
+	Message    string
+	Suggestion string
+}
+
+func (p Problem) String() string {
+	out := fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	if p.Line == 0 {
+		out = fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+
+	if p.Suggestion != "" {
+		out += " (" + p.Suggestion + ")"
+	}
+
+	return out
+}
+
+type Problems []Problem
+
+func (p Problems) Error() string {
+	lines := []string{fmt.Sprintf("%d problem(s) found in the config", len(p))}
+	for _, problem := range p {
+		lines = append(lines, problem.String())
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// report adds a problem located at the node.
+func (l *loader) report(node *yaml.Node, suggestion string, format string, args ...interface{}) {
+	problem := Problem{Message: fmt.Sprintf(format, args...), Suggestion: suggestion}
+	if node != nil {
+		problem.File = l.nodes[node]
+		problem.Line = node.Line
+	}
+
+	l.problems = append(l.problems, problem)
+}
+
+var lineRegexp = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// reportYamlError converts errors returned by the yaml decoder, they contain
+// the line in their message.
+func (l *loader) reportYamlError(file string, message string) {
+	line := 0
+	if match := lineRegexp.FindStringSubmatch(message); match != nil {
+		line, _ = strconv.Atoi(match[1])
+		message = match[2]
+	}
+
+	suggestion := ""
+	if strings.HasSuffix(message, "into bool") {
+		suggestion = "use true or false"
+	}
+
+	l.problems = append(l.problems, Problem{File: file, Line: line, Message: message, Suggestion: suggestion})
+}
+
+// checkApplications reports the problems that are only visible once the files
+// are merged: missing or duplicated names, invalid colors and invalid paths.
+func (l *loader) checkApplications(list *yaml.Node, apps []Application) {
+	if list == nil || list.Kind != yaml.SequenceNode || len(list.Content) != len(apps) {
+		return
+	}
+
+	names := map[string]*yaml.Node{}
+
+	for i, app := range apps {
+		item := list.Content[i]
+
+		if app.Name == "" {
+			l.report(item, "every application needs a unique name", "name is required")
+		} else if previous, ok := names[app.Name]; ok {
+			l.report(valueNode(item, "name"), "rename one of them",
+				"duplicate application name %q, already defined line %d", app.Name, previous.Line)
+		} else {
+			names[app.Name] = valueNode(item, "name")
+		}
+
+		if app.Color != "" && !app.Color.IsValid() {
+			var names []string
+			for _, name := range colors.Names {
+				names = append(names, string(name))
+			}
+
+			l.report(valueNode(item, "color"), suggest(string(app.Color), names),
+				"invalid color %q", string(app.Color))
+		}
+
+		switch {
+		case app.IsCommand() && app.Path != "":
+			l.report(valueNode(item, "command"), "remove path or command",
+				"an application runs either a go path or a command")
+		case app.IsCommand():
+		case app.BuildCommand != "":
+			l.report(valueNode(item, "build_command"), "add the command to run",
+				"build_command is only used with command")
+		default:
+			if message, suggestion := checkPath(app.Path); message != "" {
+				node := valueNode(item, "path")
+				if node == nil {
+					node = item
+				}
+				l.report(node, suggestion, "%s", message)
+			}
+		}
+
+		if app.Cwd != "" {
+			if info, err := os.Stat(app.Cwd); err != nil || !info.IsDir() {
+				l.report(valueNode(item, "cwd"), suggestPath(app.Cwd), "cwd %q is not a directory", app.Cwd)
+			}
+		}
+
+		if app.Module != "" {
+			if _, err := os.Stat(filepath.Join(app.Module, "go.mod")); err != nil {
+				l.report(valueNode(item, "module"), "module must be the directory of a go.mod",
+					"module %q does not contain a go.mod", app.Module)
+			}
+		}
+	}
+}
+
+// checkProfiles reports the profiles selecting applications that do not exist.
+func (l *loader) checkProfiles(profiles *yaml.Node, config *Config) {
+	var names []string
+	for _, app := range config.Apps {
+		names = append(names, app.Name)
+	}
+
+	for name, profile := range config.Profiles {
+		appsNode := valueNode(valueNode(profiles, name), "apps")
+
+		for i, app := range profile.Apps {
+			if contains(names, app) {
+				continue
+			}
+
+			var node *yaml.Node
+			if appsNode != nil && i < len(appsNode.Content) {
+				node = appsNode.Content[i]
+			}
+
+			l.report(node, suggest(app, names), "profile %q selects the unknown application %q", name, app)
+		}
+	}
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (l *loader) checkGenerators(list *yaml.Node, generators []Generator) {
+	if list == nil || list.Kind != yaml.SequenceNode || len(list.Content) != len(generators) {
+		return
+	}
+
+	names := map[string]*yaml.Node{}
+
+	for i, generator := range generators {
+		item := list.Content[i]
+
+		if generator.Name == "" {
+			l.report(item, "every generator needs a unique name", "name is required")
+		} else if previous, ok := names[generator.Name]; ok {
+			l.report(valueNode(item, "name"), "rename one of them",
+				"duplicate generator name %q, already defined line %d", generator.Name, previous.Line)
+		} else {
+			names[generator.Name] = valueNode(item, "name")
+		}
+
+		if generator.Command == "" {
+			l.report(item, "set the command generating the outputs", "command is required")
+		}
+
+		if len(generator.Inputs) == 0 {
+			l.report(item, "set the glob patterns of the files used by the command", "inputs are required")
+		}
+
+		if generator.Cwd != "" {
+			if info, err := os.Stat(generator.Cwd); err != nil || !info.IsDir() {
+				l.report(valueNode(item, "cwd"), suggestPath(generator.Cwd), "cwd %q is not a directory", generator.Cwd)
+			}
+		}
+	}
+}
+
+// checkPath verifies that the path exists and is a main package.
+func checkPath(path string) (string, string) {
+	if path == "" {
+		return "path is required", "set it to the main file or package of the application"
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err.Error(), ""
+		}
+
+		return fmt.Sprintf("path %q does not exist", path), suggestPath(path)
+	}
+
+	fileSet := token.NewFileSet()
+	packageNames := map[string]bool{}
+
+	if info.IsDir() {
+		pkgs, err := parser.ParseDir(fileSet, path, func(info os.FileInfo) bool {
+			return !strings.HasSuffix(info.Name(), "_test.go")
+		}, parser.PackageClauseOnly)
+		if err != nil {
+			return err.Error(), ""
+		}
+
+		for name := range pkgs {
+			packageNames[name] = true
+		}
+	} else {
+		f, err := parser.ParseFile(fileSet, path, nil, parser.PackageClauseOnly)
+		if err != nil {
+			return err.Error(), ""
+		}
+
+		packageNames[f.Name.Name] = true
+	}
+
+	if len(packageNames) == 0 {
+		return fmt.Sprintf("path %q does not contain any go file", path), ""
+	}
+
+	if !packageNames["main"] {
+		for name := range packageNames {
+			return fmt.Sprintf("path %q is the package %q, not a main package", path, name),
+				"use the path of a package main"
+		}
+	}
+
+	return "", ""
+}
+
+// suggestPath replaces the first segment of the path that does not exist with
+// the closest file of its parent directory.
+func suggestPath(path string) string {
+	segments := strings.Split(filepath.ToSlash(filepath.Clean(path)), "/")
+
+	for i, segment := range segments {
+		parent := filepath.Join(segments[:i]...)
+		if parent == "" {
+			parent = "."
+		}
+
+		if _, err := os.Stat(filepath.Join(parent, segment)); err == nil {
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(parent)
+		if err != nil {
+			return ""
+		}
+
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+
+		closest := closestWord(segment, names)
+		if closest == "" {
+			return ""
+		}
+
+		candidate := filepath.Join(append(append(segments[:i:i], closest), segments[i+1:]...)...)
+		if _, err := os.Stat(candidate); err != nil {
+			return ""
+		}
+
+		return fmt.Sprintf("did you mean %q?", candidate)
+	}
+
+	return ""
+}
+
+// checkKeys reports the keys of the mapping nodes that do not match any field
+// of the type the node is decoded into.
+func (l *loader) checkKeys(node *yaml.Node, t reflect.Type) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case node.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice:
+		for _, item := range node.Content {
+			l.checkKeys(item, t.Elem())
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 1; i < len(node.Content); i += 2 {
+			l.checkKeys(node.Content[i], t.Elem())
+		}
+	case node.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		fields := yamlFields(t)
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			field, ok := fields[key.Value]
+			if !ok {
+				var names []string
+				for name := range fields {
+					names = append(names, name)
+				}
+
+				l.report(key, suggest(key.Value, names), "unknown key %q", key.Value)
+				continue
+			}
+
+			l.checkKeys(value, field.Type)
+		}
+	}
+}
+
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		fields[name] = field
+	}
+
+	return fields
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateProblems(t *testing.T) {
+	tests := []struct {
+		name  string
+		files []string
+		want  Problems
+	}{
+		{
+			name:  "single file",
+			files: []string{"testdata/problems.yaml"},
+			want: Problems{
+				{File: "testdata/problems.yaml", Line: 4, Message: `unknown key "colour"`, Suggestion: `did you mean "color"?`},
+				{File: "testdata/problems.yaml", Line: 5, Message: `duplicate application name "api", already defined line 2`, Suggestion: "rename one of them"},
+				{File: "testdata/problems.yaml", Line: 8, Message: `invalid color "pink"`},
+				{File: "testdata/problems.yaml", Line: 10, Message: "name is required", Suggestion: "every application needs a unique name"},
+			},
+		},
+		{
+			name:  "duplicate in a file merged on top of another",
+			files: []string{"testdata/duplicates.yaml"},
+			want: Problems{
+				{File: "testdata/duplicates.yaml", Line: 5, Message: `duplicate application name "worker", already defined line 3`, Suggestion: "rename one of them"},
+			},
+		},
+		{
+			name:  "valid files",
+			files: []string{"testdata/base.yaml", "testdata/local.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, problems, err := Validate(tt.files, "")
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(problems, tt.want) {
+				t.Errorf("Validate() problems =\n%v\nwant\n%v", problems, tt.want)
+			}
+		})
+	}
+}
+
+func TestProblemString(t *testing.T) {
+	tests := []struct {
+		problem Problem
+		want    string
+	}{
+		{
+			problem: Problem{File: ".gomon.yaml", Line: 3, Message: `unknown key "colour"`, Suggestion: `did you mean "color"?`},
+			want:    `.gomon.yaml:3: unknown key "colour" (did you mean "color"?)`,
+		},
+		{
+			problem: Problem{File: ".gomon.yaml", Message: `unknown profile "back"`},
+			want:    `.gomon.yaml: unknown profile "back"`,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.problem.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
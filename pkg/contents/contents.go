@@ -0,0 +1,174 @@
+package contents
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Change describes how a file changed since its previous version.
+type Change int
+
+const (
+	// Unchanged means the bytes of the file are the same.
+	Unchanged Change = iota
+	// CommentsOnly means only the comments or the spacing of a go file
+	// changed.
+	CommentsOnly
+	// Changed means the content of the file changed.
+	Changed
+)
+
+type hashes struct {
+	content string
+	// syntax is the hash of the go file without its comments and positions,
+	// empty when it can not be compared.
+	syntax string
+}
+
+// Hashes keeps a hash of the files to know whether a write event changed them.
+type Hashes struct {
+	mutex sync.Mutex
+	files map[string]hashes
+}
+
+func NewHashes() *Hashes {
+	return &Hashes{
+		files: map[string]hashes{},
+	}
+}
+
+// Track hashes the files that are not known yet, so their first write can be
+// compared.
+func (h *Hashes) Track(files []string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for _, file := range files {
+		if _, ok := h.files[file]; ok {
+			continue
+		}
+
+		if hashes, err := hash(file); err == nil {
+			h.files[file] = hashes
+		}
+	}
+}
+
+// Update hashes the file again and returns how it changed. A file that was not
+// tracked is always changed.
+func (h *Hashes) Update(file string) Change {
+	current, err := hash(file)
+	if err != nil {
+		return Changed
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	previous, ok := h.files[file]
+	h.files[file] = current
+
+	switch {
+	case !ok:
+		return Changed
+	case previous.content == current.content:
+		return Unchanged
+	case previous.syntax != "" && previous.syntax == current.syntax:
+		return CommentsOnly
+	default:
+		return Changed
+	}
+}
+
+// Remove forgets a file that was deleted.
+func (h *Hashes) Remove(file string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	delete(h.files, file)
+}
+
+func hash(file string) (hashes, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return hashes{}, err
+	}
+
+	sum := md5.Sum(content)
+	hashes := hashes{content: hex.EncodeToString(sum[:])}
+
+	if strings.HasSuffix(file, ".go") {
+		hashes.syntax = syntaxHash(file, content)
+	}
+
+	return hashes, nil
+}
+
+var (
+	posType          = reflect.TypeOf(token.NoPos)
+	commentGroupType = reflect.TypeOf(&ast.CommentGroup{})
+	commentsType     = reflect.TypeOf([]*ast.CommentGroup{})
+)
+
+// syntaxHash returns the hash of the printed syntax tree of a go file, without
+// positions and comments. The comments that change the build, such as
+// directives and cgo preambles, are part of the hash.
+func syntaxHash(file string, content []byte) string {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return ""
+	}
+
+	buffer := &bytes.Buffer{}
+
+	for _, spec := range f.Imports {
+		if spec.Path.Value == `"C"` {
+			// the preamble of cgo is a comment
+			return ""
+		}
+	}
+
+	for _, group := range f.Comments {
+		for _, comment := range group.List {
+			if isDirective(comment.Text) {
+				buffer.WriteString(comment.Text + "\n")
+			}
+		}
+	}
+
+	err = ast.Fprint(buffer, fset, f, func(name string, value reflect.Value) bool {
+		switch value.Type() {
+		case posType, commentGroupType, commentsType:
+			return false
+		}
+
+		return ast.NotNilFilter(name, value)
+	})
+	if err != nil {
+		return ""
+	}
+
+	sum := md5.Sum(buffer.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// isDirective reports whether the comment is read by the go tools: build
+// constraints, //go: directives, //line and //export.
+func isDirective(comment string) bool {
+	for _, prefix := range []string{"//go:", "// +build", "//+build", "//line ", "//export "} {
+		if strings.HasPrefix(comment, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,167 @@
+package contents
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHashesUpdate(t *testing.T) {
+	const source = `package greet
+
+// Hello greets.
+func Hello() string {
+	return "hello"
+}
+`
+
+	tests := []struct {
+		name   string
+		file   string
+		before string
+		after  string
+		want   Change
+	}{
+		{
+			name:   "same content",
+			before: source,
+			after:  source,
+			want:   Unchanged,
+		},
+		{
+			name:   "comment changed",
+			before: source,
+			after:  "package greet\n\n// Hello says hello.\nfunc Hello() string {\n\treturn \"hello\" // inline\n}\n",
+			want:   CommentsOnly,
+		},
+		{
+			name:   "spacing changed",
+			before: source,
+			after:  "package greet\n\n\n// Hello greets.\nfunc Hello() string { return \"hello\" }\n",
+			want:   CommentsOnly,
+		},
+		{
+			name:   "code changed",
+			before: source,
+			after:  "package greet\n\n// Hello greets.\nfunc Hello() string {\n\treturn \"hi\"\n}\n",
+			want:   Changed,
+		},
+		{
+			name:   "directive added",
+			before: source,
+			after:  "package greet\n\n// Hello greets.\n//go:noinline\nfunc Hello() string {\n\treturn \"hello\"\n}\n",
+			want:   Changed,
+		},
+		{
+			name:   "go:build constraint changed",
+			before: "//go:build linux\n\n" + source,
+			after:  "//go:build windows\n\n" + source,
+			want:   Changed,
+		},
+		{
+			name:   "+build constraint changed",
+			before: "// +build linux\n\n" + source,
+			after:  "// +build windows\n\n" + source,
+			want:   Changed,
+		},
+		{
+			name:   "cgo preamble changed",
+			before: "package greet\n\n// #include <stdio.h>\nimport \"C\"\n",
+			after:  "package greet\n\n// #include <stdlib.h>\nimport \"C\"\n",
+			want:   Changed,
+		},
+		{
+			name:   "invalid go file",
+			before: "package greet\n\nfunc {\n",
+			after:  "package greet\n\n// comment\nfunc {\n",
+			want:   Changed,
+		},
+		{
+			name:   "comment like text of another file",
+			file:   "notes.txt",
+			before: "// first\nvalue\n",
+			after:  "// second\nvalue\n",
+			want:   Changed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "gomon-contents")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+
+			name := tt.file
+			if name == "" {
+				name = "greet.go"
+			}
+			file := filepath.Join(dir, name)
+
+			if err := ioutil.WriteFile(file, []byte(tt.before), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			hashes := NewHashes()
+			hashes.Track([]string{file})
+
+			if err := ioutil.WriteFile(file, []byte(tt.after), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := hashes.Update(file); got != tt.want {
+				t.Errorf("Update() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashesUpdateUntracked(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomon-contents")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "greet.go")
+	if err := ioutil.WriteFile(file, []byte("package greet\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	hashes := NewHashes()
+	if got := hashes.Update(file); got != Changed {
+		t.Errorf("first Update() = %d, want Changed", got)
+	}
+	if got := hashes.Update(file); got != Unchanged {
+		t.Errorf("second Update() = %d, want Unchanged", got)
+	}
+
+	hashes.Remove(file)
+	if got := hashes.Update(file); got != Changed {
+		t.Errorf("Update() after Remove = %d, want Changed", got)
+	}
+}
+
+func TestSyntaxHash(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		empty   bool
+	}{
+		{name: "go file", content: "package greet\n\nfunc Hello() {}\n"},
+		{name: "cgo file", content: "package greet\n\n// #include <stdio.h>\nimport \"C\"\n", empty: true},
+		{name: "cgo import in a group", content: "package greet\n\nimport (\n\t\"fmt\"\n\t\"C\"\n)\n\nvar _ = fmt.Sprint\n", empty: true},
+		{name: "invalid go file", content: "package greet\n\nfunc {\n", empty: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := syntaxHash("greet.go", []byte(tt.content))
+			if (got == "") != tt.empty {
+				t.Errorf("syntaxHash() = %q, want empty %v", got, tt.empty)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package envfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Lookup returns the value of an environment variable and whether it is set.
+type Lookup func(key string) (string, bool)
+
+// Read parses the env file at path. Values are interpolated with the variables
+// previously defined in the file, then with lookup.
+func Read(path string, lookup Lookup) (map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	values, err := Parse(file, lookup)
+	if err != nil {
+		return nil, errors.Wrap(err, path)
+	}
+
+	return values, nil
+}
+
+// Parse reads lines of KEY=VALUE. Empty lines and lines starting with # are
+// ignored, a leading "export " is allowed, values can be quoted with double
+// quotes (escape sequences and interpolation) or single quotes (raw value).
+func Parse(r io.Reader, lookup Lookup) (map[string]string, error) {
+	values := map[string]string{}
+	chained := func(key string) (string, bool) {
+		if v, ok := values[key]; ok {
+			return v, true
+		}
+		if lookup != nil {
+			return lookup(key)
+		}
+		return "", false
+	}
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+
+		i := strings.Index(line, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNumber)
+		}
+
+		key := strings.TrimSpace(line[:i])
+		value, err := parseValue(strings.TrimSpace(line[i+1:]), chained)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", lineNumber, err)
+		}
+
+		values[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func parseValue(raw string, lookup Lookup) (string, error) {
+	if raw == "" {
+		return "", nil
+	}
+
+	switch raw[0] {
+	case '\'':
+		end := strings.Index(raw[1:], "'")
+		if end < 0 {
+			return "", errors.New("unterminated single quoted value")
+		}
+		return raw[1 : end+1], nil
+	case '"':
+		var value strings.Builder
+		for i := 1; i < len(raw); i++ {
+			switch c := raw[i]; {
+			case c == '"':
+				return Expand(value.String(), lookup), nil
+			case c == '\\' && i+1 < len(raw):
+				i++
+				switch raw[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case 't':
+					value.WriteByte('\t')
+				default:
+					value.WriteByte(raw[i])
+				}
+			default:
+				value.WriteByte(c)
+			}
+		}
+		return "", errors.New("unterminated double quoted value")
+	}
+
+	// inline comments need a space before the # to keep values like a#b
+	if i := strings.Index(raw, " #"); i >= 0 {
+		raw = strings.TrimSpace(raw[:i])
+	}
+
+	return Expand(raw, lookup), nil
+}
+
+// Expand replaces $VAR, ${VAR} and ${VAR:-default} in value. The default is
+// used when the variable is unset or empty, it can contain variables too.
+func Expand(value string, lookup Lookup) string {
+	var expanded strings.Builder
+
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 == len(value) {
+			expanded.WriteByte(value[i])
+			continue
+		}
+
+		switch next := value[i+1]; {
+		case next == '{':
+			end := closingBrace(value, i+2)
+			if end < 0 {
+				expanded.WriteString(value[i:])
+				return expanded.String()
+			}
+
+			expanded.WriteString(expandExpression(value[i+2:end], lookup))
+			i = end
+		case isNameByte(next, true):
+			end := i + 1
+			for end < len(value) && isNameByte(value[end], false) {
+				end++
+			}
+
+			v, _ := get(lookup, value[i+1:end])
+			expanded.WriteString(v)
+			i = end - 1
+		default:
+			expanded.WriteByte('$')
+		}
+	}
+
+	return expanded.String()
+}
+
+// expandExpression expands the content of ${...}.
+func expandExpression(expr string, lookup Lookup) string {
+	key, def, hasDefault := expr, "", false
+	if i := strings.Index(expr, ":-"); i >= 0 {
+		key, def, hasDefault = expr[:i], expr[i+2:], true
+	}
+
+	v, ok := get(lookup, key)
+	if hasDefault && (!ok || v == "") {
+		return Expand(def, lookup)
+	}
+
+	return v
+}
+
+func get(lookup Lookup, key string) (string, bool) {
+	if lookup == nil {
+		return "", false
+	}
+
+	return lookup(key)
+}
+
+// closingBrace returns the index of the brace closing the one before start,
+// or -1.
+func closingBrace(value string, start int) int {
+	depth := 1
+	for i := start; i < len(value); i++ {
+		switch value[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+
+	return -1
+}
+
+func isNameByte(c byte, first bool) bool {
+	return c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || !first && '0' <= c && c <= '9'
+}
@@ -0,0 +1,151 @@
+package envfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func lookupFrom(values map[string]string) Lookup {
+	return func(key string) (string, bool) {
+		v, ok := values[key]
+		return v, ok
+	}
+}
+
+func TestParse(t *testing.T) {
+	env := lookupFrom(map[string]string{"HOST": "db", "EMPTY": ""})
+
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]string
+	}{
+		{
+			name:  "plain values",
+			input: "A=1\nB = two \n",
+			want:  map[string]string{"A": "1", "B": "two"},
+		},
+		{
+			name:  "comments and empty lines",
+			input: "# comment\n\n  # indented comment\nA=1 # inline\nB=a#b\n",
+			want:  map[string]string{"A": "1", "B": "a#b"},
+		},
+		{
+			name:  "export prefix",
+			input: "export A=1\n",
+			want:  map[string]string{"A": "1"},
+		},
+		{
+			name:  "double quotes",
+			input: `A="a b # not a comment"` + "\n" + `B="line\nnext\t\"quoted\""`,
+			want:  map[string]string{"A": "a b # not a comment", "B": "line\nnext\t\"quoted\""},
+		},
+		{
+			name:  "single quotes are raw",
+			input: `A='${HOST} \n'`,
+			want:  map[string]string{"A": `${HOST} \n`},
+		},
+		{
+			name:  "interpolation from the lookup",
+			input: "A=${HOST}:5432\nB=$HOST\nC=\"${HOST}\"\n",
+			want:  map[string]string{"A": "db:5432", "B": "db", "C": "db"},
+		},
+		{
+			name:  "defaults",
+			input: "A=${MISSING:-localhost}\nB=${EMPTY:-fallback}\nC=${HOST:-unused}\nD=${MISSING:-${HOST}}\n",
+			want:  map[string]string{"A": "localhost", "B": "fallback", "C": "db", "D": "db"},
+		},
+		{
+			name:  "previous values of the file win over the lookup",
+			input: "HOST=local\nURL=postgres://${HOST}\n",
+			want:  map[string]string{"HOST": "local", "URL": "postgres://local"},
+		},
+		{
+			name:  "a value is only interpolated with the values defined before",
+			input: "URL=${PORT:-80}\nPORT=8080\n",
+			want:  map[string]string{"URL": "80", "PORT": "8080"},
+		},
+		{
+			name:  "the last definition wins",
+			input: "A=1\nA=2\n",
+			want:  map[string]string{"A": "2"},
+		},
+		{
+			name:  "empty value",
+			input: "A=\n",
+			want:  map[string]string{"A": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(strings.NewReader(tt.input), env)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "missing equal sign", input: "A=1\nINVALID\n", want: "line 2: expected KEY=VALUE"},
+		{name: "missing key", input: "=1\n", want: "line 1: expected KEY=VALUE"},
+		{name: "unterminated double quote", input: `A="abc`, want: "line 1: unterminated double quoted value"},
+		{name: "unterminated single quote", input: `A='abc`, want: "line 1: unterminated single quoted value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.input), nil)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Parse() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	env := lookupFrom(map[string]string{"PORT": "8080", "EMPTY": ""})
+
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "plain", want: "plain"},
+		{value: "$PORT", want: "8080"},
+		{value: "${PORT}", want: "8080"},
+		{value: "--port=${PORT}", want: "--port=8080"},
+		{value: "${MISSING}", want: ""},
+		{value: "${MISSING:-80}", want: "80"},
+		{value: "${EMPTY:-80}", want: "80"},
+		{value: "${PORT:-80}", want: "8080"},
+		{value: "${MISSING:-${PORT}}", want: "8080"},
+		{value: "${MISSING:-}", want: ""},
+		{value: "${MISSING:-$PORT}", want: "8080"},
+		{value: "${MISSING:-${OTHER:-${PORT}}}/x", want: "8080/x"},
+		{value: "$PORT$PORT", want: "80808080"},
+		{value: "${PORT}_1", want: "8080_1"},
+		{value: "$PORT_1", want: ""},
+		{value: "cost: 5$", want: "cost: 5$"},
+		{value: "a $ b", want: "a $ b"},
+		{value: "${PORT", want: "${PORT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			if got := Expand(tt.value, env); got != tt.want {
+				t.Errorf("Expand(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"io"
 	"os"
 	"path"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 )
@@ -18,6 +19,11 @@ func GetName() (string, error) {
 		return "", errors.Wrap(err, ErrUnableToGetGoMod)
 	}
 
+	return GetNameFromDir(dir)
+}
+
+// GetNameFromDir returns the name of the module whose go.mod is in dir.
+func GetNameFromDir(dir string) (string, error) {
 	goModPath := path.Join(dir, "go.mod")
 
 	file, err := os.Open(goModPath)
@@ -44,6 +50,32 @@ func GetName() (string, error) {
 	}
 }
 
+// FindDir returns the closest directory containing a go.mod, starting from the
+// directory of path and going up.
+func FindDir(path string) (string, error) {
+	dir := path
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		dir = filepath.Dir(path)
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.mod")); err == nil {
+			return dir, nil
+		}
+
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return "", errors.Wrap(err, ErrUnableToGetGoMod)
+		}
+
+		if filepath.Dir(abs) == abs {
+			return "", errors.Errorf("%s: no go.mod found in %s or its parents", ErrUnableToGetGoMod, path)
+		}
+
+		dir = filepath.Join(dir, "..")
+	}
+}
+
 func getModuleName(line []byte) string {
 	lineSplit := bytes.SplitN(line, []byte("module"), 2)
 	if len(lineSplit) != 2 {
@@ -79,3 +111,55 @@ func getFullLine(reader *bufio.Reader) ([]byte, error) {
 
 	return line, nil
 }
+
+// FindWorkFile returns the go.work used for the module in dir, as the go
+// command does: GOWORK when it is set, or the closest go.work above dir.
+func FindWorkFile(dir string) string {
+	switch gowork := os.Getenv("GOWORK"); gowork {
+	case "off":
+		return ""
+	case "":
+	default:
+		return gowork
+	}
+
+	for {
+		if _, err := os.Stat(filepath.Join(dir, "go.work")); err == nil {
+			return filepath.Join(dir, "go.work")
+		}
+
+		abs, err := filepath.Abs(dir)
+		if err != nil || filepath.Dir(abs) == abs {
+			return ""
+		}
+
+		dir = filepath.Join(dir, "..")
+	}
+}
+
+// Files returns the files describing the dependencies of the module in dir:
+// go.mod, go.sum, vendor/modules.txt and the go.work of the workspace.
+func Files(dir string) []string {
+	files := []string{
+		filepath.Join(dir, "go.mod"),
+		filepath.Join(dir, "go.sum"),
+		filepath.Join(dir, "vendor", "modules.txt"),
+	}
+
+	if workFile := FindWorkFile(dir); workFile != "" {
+		files = append(files, workFile, workFile+".sum")
+	}
+
+	return files
+}
+
+// IsFile reports whether the base name of the file is one of the files
+// describing dependencies.
+func IsFile(file string) bool {
+	switch filepath.Base(file) {
+	case "go.mod", "go.sum", "go.work", "go.work.sum", "modules.txt":
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,67 @@
+package imports
+
+import (
+	"path/filepath"
+
+	"github.com/expectedsh/gomon/pkg/config"
+	"github.com/expectedsh/gomon/pkg/gomodule"
+)
+
+// OptionsFor returns the options matching the build of the application, the
+// dependencies are listed with the same tags and environment.
+func OptionsFor(app config.Application, moduleDir string) Options {
+	flags := append([]string{}, app.Build.Flags...)
+	if app.Build.Race {
+		flags = append(flags, "-race")
+	}
+
+	return Options{
+		Dir:   moduleDir,
+		Env:   app.Build.Environ(),
+		Tags:  app.Build.Tags,
+		Flags: flags,
+	}
+}
+
+// LoadApplication returns the graph of the packages built into the
+// application, it is empty for a command.
+func LoadApplication(app config.Application) (*Graph, error) {
+	if app.IsCommand() {
+		return &Graph{
+			Packages: map[string]*Package{},
+			Files:    map[string]string{},
+			Modules:  map[string]string{},
+		}, nil
+	}
+
+	moduleDir, err := app.ModuleDir()
+	if err != nil {
+		return nil, err
+	}
+
+	return Load(app.BuildPath(moduleDir), OptionsFor(app, moduleDir))
+}
+
+// ModuleFiles returns the files describing the dependencies of an application
+// built in moduleDir: the module files of its module and of its workspace, and
+// the go.mod and go.sum of the other local modules of its graph.
+func ModuleFiles(moduleDir string, graph *Graph) []string {
+	files := gomodule.Files(moduleDir)
+	if graph == nil {
+		return files
+	}
+
+	modules := map[string]bool{}
+	for _, pkg := range graph.SortedPackages() {
+		if modules[pkg.Module] {
+			continue
+		}
+		modules[pkg.Module] = true
+
+		if dir, err := gomodule.FindDir(pkg.Dir); err == nil && filepath.Clean(dir) != filepath.Clean(moduleDir) {
+			files = append(files, filepath.Join(dir, "go.mod"), filepath.Join(dir, "go.sum"))
+		}
+	}
+
+	return files
+}
@@ -0,0 +1,92 @@
+package imports
+
+import (
+	"go/build"
+	"path/filepath"
+	"strings"
+)
+
+// Context returns the build context described by the options: GOOS, GOARCH
+// and CGO_ENABLED of the build environment, and the tags.
+func (o Options) Context() build.Context {
+	ctx := build.Default
+	ctx.BuildTags = o.buildTags()
+
+	for _, kv := range o.Env {
+		i := strings.Index(kv, "=")
+		if i < 0 {
+			continue
+		}
+
+		switch key, value := kv[:i], kv[i+1:]; key {
+		case "GOOS":
+			ctx.GOOS = value
+		case "GOARCH":
+			ctx.GOARCH = value
+		case "CGO_ENABLED":
+			ctx.CgoEnabled = value == "1"
+		}
+	}
+
+	return ctx
+}
+
+// buildTags returns the tags the go command builds with. The -tags of the
+// flags are replaced by Tags, given after them, and -race adds the race tag.
+func (o Options) buildTags() []string {
+	var tags []string
+	race := false
+
+	for i, flag := range o.Flags {
+		flag = "-" + strings.TrimLeft(flag, "-")
+
+		switch {
+		case flag == "-race" || flag == "-race=true":
+			race = true
+		case flag == "-race=false":
+			race = false
+		case strings.HasPrefix(flag, "-tags="):
+			tags = splitTags(strings.TrimPrefix(flag, "-tags="))
+		case flag == "-tags" && i+1 < len(o.Flags):
+			tags = splitTags(o.Flags[i+1])
+		}
+	}
+
+	if len(o.Tags) > 0 {
+		tags = append([]string{}, o.Tags...)
+	}
+
+	if race {
+		tags = append(tags, "race")
+	}
+
+	return tags
+}
+
+// splitTags splits a -tags value, a comma separated list or the older space
+// separated one.
+func splitTags(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
+
+// MatchFile reports whether a go file is part of the build described by the
+// options, evaluating its GOOS and GOARCH suffixes and its build constraints.
+// Files that are not go files always match.
+func (o Options) MatchFile(file string) bool {
+	if !strings.HasSuffix(file, ".go") {
+		return true
+	}
+
+	ctx := o.Context()
+
+	match, err := ctx.MatchFile(filepath.Dir(file), filepath.Base(file))
+	if err != nil {
+		// a file that can not be read, while being written for example, can
+		// not be excluded
+		return true
+	}
+
+	return match
+}
@@ -0,0 +1,96 @@
+package imports
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		options Options
+		want    []string
+	}{
+		{name: "no tags", options: Options{}},
+		{name: "tags", options: Options{Tags: []string{"a", "b"}}, want: []string{"a", "b"}},
+		{name: "-tags= comma separated", options: Options{Flags: []string{"-tags=a,b"}}, want: []string{"a", "b"}},
+		{name: "-tags space separated", options: Options{Flags: []string{"-tags", "a b"}}, want: []string{"a", "b"}},
+		{name: "--tags", options: Options{Flags: []string{"--tags=a"}}, want: []string{"a"}},
+		{name: "last -tags wins", options: Options{Flags: []string{"-tags=a", "-tags=b"}}, want: []string{"b"}},
+		{name: "tags replace -tags", options: Options{Flags: []string{"-tags=a"}, Tags: []string{"b"}}, want: []string{"b"}},
+		{name: "-tags without value", options: Options{Flags: []string{"-tags"}}},
+		{name: "-race", options: Options{Flags: []string{"-race"}}, want: []string{"race"}},
+		{name: "--race=true", options: Options{Flags: []string{"--race=true"}}, want: []string{"race"}},
+		{name: "-race=false", options: Options{Flags: []string{"-race", "-race=false"}}},
+		{name: "-race with tags", options: Options{Flags: []string{"-race", "-mod=vendor"}, Tags: []string{"a"}}, want: []string{"a", "race"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.buildTags(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildTags() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gomon-constraints")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"foo.go":             "package foo\n",
+		"foo_windows.go":     "package foo\n",
+		"foo_linux_arm64.go": "package foo\n",
+		"integration.go":     "//go:build integration\n\npackage foo\n",
+		"integration_old.go": "// +build integration\n\npackage foo\n",
+		"not_race.go":        "//go:build !race\n\npackage foo\n",
+		"cgo.go":             "//go:build cgo\n\npackage foo\n",
+		"schema.sql":         "-- +build integration\n",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	linux := []string{"GOOS=linux", "GOARCH=amd64", "CGO_ENABLED=0"}
+
+	tests := []struct {
+		name    string
+		options Options
+		file    string
+		want    bool
+	}{
+		{name: "no constraint", options: Options{Env: linux}, file: "foo.go", want: true},
+		{name: "other GOOS", options: Options{Env: linux}, file: "foo_windows.go", want: false},
+		{name: "GOOS override", options: Options{Env: []string{"GOOS=windows"}}, file: "foo_windows.go", want: true},
+		{name: "other GOARCH", options: Options{Env: linux}, file: "foo_linux_arm64.go", want: false},
+		{name: "GOARCH override", options: Options{Env: []string{"GOOS=linux", "GOARCH=arm64"}}, file: "foo_linux_arm64.go", want: true},
+		{name: "go:build without the tag", options: Options{Env: linux}, file: "integration.go", want: false},
+		{name: "go:build with the tag", options: Options{Env: linux, Tags: []string{"integration"}}, file: "integration.go", want: true},
+		{name: "go:build with -tags", options: Options{Env: linux, Flags: []string{"-tags=integration"}}, file: "integration.go", want: true},
+		{name: "+build without the tag", options: Options{Env: linux}, file: "integration_old.go", want: false},
+		{name: "+build with the tag", options: Options{Env: linux, Tags: []string{"integration"}}, file: "integration_old.go", want: true},
+		{name: "without -race", options: Options{Env: linux}, file: "not_race.go", want: true},
+		{name: "with -race", options: Options{Env: linux, Flags: []string{"-race"}}, file: "not_race.go", want: false},
+		{name: "cgo disabled", options: Options{Env: linux}, file: "cgo.go", want: false},
+		{name: "cgo enabled", options: Options{Env: []string{"CGO_ENABLED=1"}}, file: "cgo.go", want: true},
+		{name: "not a go file", options: Options{Env: linux}, file: "schema.sql", want: true},
+		{name: "missing file", options: Options{Env: linux}, file: "missing.go", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.options.MatchFile(filepath.Join(dir, tt.file)); got != tt.want {
+				t.Errorf("MatchFile(%q) = %v, want %v", tt.file, got, tt.want)
+			}
+		})
+	}
+}
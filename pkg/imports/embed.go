@@ -0,0 +1,76 @@
+package imports
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/expectedsh/gomon/pkg/utils"
+)
+
+// EmbeddingPackage returns the package embedding the file with a //go:embed
+// directive, even if the file was created after the graph was loaded.
+func (g *Graph) EmbeddingPackage(file string) (*Package, bool) {
+	for _, pkg := range g.Packages {
+		if pkg.Embeds(file) {
+			return pkg, true
+		}
+	}
+
+	return nil, false
+}
+
+// Embeds reports whether the file matches one of the embed patterns of the
+// package. As for the go command, a pattern matching a directory embeds all
+// its files except the ones starting with . or _, unless the pattern starts
+// with all:.
+func (p *Package) Embeds(file string) bool {
+	rel, err := filepath.Rel(p.Dir, file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+
+	segments := strings.Split(filepath.ToSlash(rel), "/")
+
+	for _, pattern := range p.EmbedPatterns {
+		all := strings.HasPrefix(pattern, "all:")
+		pattern = strings.TrimPrefix(pattern, "all:")
+
+		for i := len(segments); i >= 1; i-- {
+			if ok, _ := path.Match(pattern, strings.Join(segments[:i], "/")); !ok {
+				continue
+			}
+
+			if i == len(segments) || all || !hasHiddenSegment(segments[i:]) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// EmbedRoots returns the directories containing the embedded files, they need
+// to be watched with their sub directories to detect new files.
+func (p *Package) EmbedRoots() []string {
+	var roots []string
+
+	for _, pattern := range p.EmbedPatterns {
+		base := utils.GlobBase(strings.TrimPrefix(pattern, "all:"))
+		if base != "." {
+			roots = append(roots, filepath.Join(p.Dir, base))
+		}
+	}
+
+	return roots
+}
+
+func hasHiddenSegment(segments []string) bool {
+	for _, segment := range segments {
+		if strings.HasPrefix(segment, ".") || strings.HasPrefix(segment, "_") {
+			return true
+		}
+	}
+
+	return false
+}
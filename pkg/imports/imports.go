@@ -1,102 +1,252 @@
 package imports
 
 import (
-	"go/ast"
-	"go/parser"
-	"go/token"
-	"io/ioutil"
+	"bytes"
+	"encoding/json"
+	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+
+	"github.com/pkg/errors"
 )
 
-func FromFile(repo string, filepath string, result map[string]bool) {
-	result[filepath] = true
+// Graph is the set of local packages that are built into a binary, with the
+// files of every package. Local packages are the packages of the main module,
+// of the modules of the go.work workspace and of the modules replaced by a
+// local directory.
+type Graph struct {
+	// Main is the import path of the main package.
+	Main     string
+	Packages map[string]*Package
+	// Files associates every file of the graph to the import path of its
+	// package.
+	Files map[string]string
+	// Modules associates every module the binary is built with, local or not,
+	// to its version and its replacement.
+	Modules map[string]string
+}
 
-	for _, importPath := range getImports(filepath) {
-		if strings.HasPrefix(importPath, repo) {
-			pkgPath := strings.Replace(importPath, repo+"/", "", -1)
-			if _, alreadyChecked := result[pkgPath]; !alreadyChecked {
-				// add in cache this pkg
-				result[pkgPath] = true
+type Package struct {
+	ImportPath string `json:"import_path"`
+	Module     string `json:"module"`
+	// Dir and Files are relative to the gomon working directory.
+	Dir     string   `json:"dir"`
+	Files   []string `json:"files"`
+	Imports []string `json:"imports"`
+	// EmbedPatterns are the patterns of the //go:embed directives, relative to
+	// the directory of the package.
+	EmbedPatterns []string `json:"embed_patterns,omitempty"`
+	// FileImports are the imports of every go file, to know whether the
+	// graph must be updated after a change.
+	FileImports map[string][]string `json:"-"`
+}
 
-				files := getFiles(pkgPath)
+// Options describe how the go command is run to list the packages, they must
+// match the options of the build of the application.
+type Options struct {
+	// Dir is the directory of the module.
+	Dir   string
+	Env   []string
+	Tags  []string
+	Flags []string
+}
 
-				// for each files in this pkgPath add to result all imports
-				for _, file := range files {
-					result[file] = true
-					FromFile(repo, file, result)
-				}
-			}
-		}
+// listedPackage is the subset of the output of go list used by gomon.
+type listedPackage struct {
+	ImportPath string
+	Dir        string
+	Standard   bool
+	Imports    []string
+	Module     *listedModule
+
+	GoFiles    []string
+	CgoFiles   []string
+	CFiles     []string
+	CXXFiles   []string
+	HFiles     []string
+	SFiles     []string
+	SysoFiles  []string
+	EmbedFiles []string
+	OtherFiles []string
+
+	EmbedPatterns []string
+}
+
+type listedModule struct {
+	Path    string
+	Main    bool
+	Version string
+	Replace *listedModule
+}
+
+func (m *listedModule) path() string {
+	if m == nil {
+		return ""
+	}
+
+	return m.Path
+}
+
+// version returns the version of the module with its replacement.
+func (m *listedModule) version() string {
+	if m.Replace == nil {
+		return m.Version
 	}
+
+	return m.Version + " => " + m.Replace.Path + " " + m.Replace.Version
 }
 
-func getImports(filepath string) []string {
-	set := token.NewFileSet()
-	bytes, err := ioutil.ReadFile(filepath)
+// isLocal reports whether the sources of the module are on the disk and can be
+// edited: the main modules, including the ones of the workspace, and the
+// modules replaced by a directory.
+func (m *listedModule) isLocal() bool {
+	if m == nil {
+		return false
+	}
+
+	return m.Main || m.Replace != nil && m.Replace.Version == ""
+}
+
+// Load returns the graph of the main package at path, built from the packages
+// listed by go list -deps.
+func Load(path string, options Options) (*Graph, error) {
+	listed, err := list(path, options)
 	if err != nil {
-		return nil
+		return nil, err
 	}
-	astFile, err := parser.ParseFile(set, filepath, bytes, 0)
+
+	wd, err := os.Getwd()
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	var paths []string
-	importList := imports(set, astFile)
-	for _, list := range importList {
-		for _, i := range list {
-			paths = append(paths, i.Path.Value[1:len(i.Path.Value)-1])
+	graph := &Graph{
+		Packages: map[string]*Package{},
+		Files:    map[string]string{},
+		Modules:  map[string]string{},
+	}
+
+	for _, pkg := range listed {
+		if pkg.Module != nil {
+			graph.Modules[pkg.Module.Path] = pkg.Module.version()
+		}
+
+		if pkg.Standard || !pkg.Module.isLocal() {
+			continue
 		}
+
+		graph.add(wd, pkg)
 	}
 
-	return paths
+	// go list -deps prints the dependencies first, the main package last. A
+	// main package given as a file is listed as command-line-arguments,
+	// without module.
+	if len(listed) > 0 {
+		main := listed[len(listed)-1]
+
+		graph.Main = main.ImportPath
+		if _, ok := graph.Packages[main.ImportPath]; !ok && !main.Standard {
+			graph.add(wd, main)
+		}
+	}
+
+	return graph, nil
 }
 
-func imports(tokenFileSet *token.FileSet, f *ast.File) [][]*ast.ImportSpec {
-	var groups [][]*ast.ImportSpec
+// list runs go list -deps on the path with the options of the build.
+func list(path string, options Options) ([]listedPackage, error) {
+	args := []string{"list", "-e", "-deps", "-json"}
+	args = append(args, options.Flags...)
+	if len(options.Tags) > 0 {
+		args = append(args, "-tags", strings.Join(options.Tags, ","))
+	}
+
+	cmd := exec.Command("go", append(args, path)...)
+	cmd.Dir = options.Dir
+	cmd.Env = append(os.Environ(), options.Env...)
 
-	for _, decl := range f.Decls {
-		genDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genDecl.Tok != token.IMPORT {
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to list the packages of %s: %s", path, strings.TrimSpace(stderr.String()))
+	}
+
+	var packages []listedPackage
+
+	decoder := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var listed listedPackage
+		if err := decoder.Decode(&listed); err == io.EOF {
 			break
+		} else if err != nil {
+			return nil, errors.Wrap(err, "unable to read the output of go list")
 		}
 
-		var group []*ast.ImportSpec
-
-		var lastLine int
-		for _, spec := range genDecl.Specs {
-			importSpec := spec.(*ast.ImportSpec)
-			pos := importSpec.Path.ValuePos
-			line := tokenFileSet.Position(pos).Line
-			if lastLine > 0 && pos > 0 && line-lastLine > 1 {
-				groups = append(groups, group)
-				group = []*ast.ImportSpec{}
-			}
-			group = append(group, importSpec)
-			lastLine = line
-		}
-		groups = append(groups, group)
+		packages = append(packages, listed)
 	}
 
-	return groups
+	return packages, nil
 }
 
-func getFiles(pkgPath string) (files []string) {
-	if err := filepath.Walk(pkgPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// SortedPackages returns the packages of the graph sorted by import path.
+func (g *Graph) SortedPackages() []*Package {
+	packages := make([]*Package, 0, len(g.Packages))
+	for _, pkg := range g.Packages {
+		packages = append(packages, pkg)
+	}
+
+	sort.Slice(packages, func(i, j int) bool {
+		return packages[i].ImportPath < packages[j].ImportPath
+	})
+
+	return packages
+}
+
+func (g *Graph) add(wd string, listed listedPackage) {
+	dir := relative(wd, listed.Dir)
+
+	pkg := &Package{
+		ImportPath: listed.ImportPath,
+		Module:     listed.Module.path(),
+		Dir:        dir,
+		Imports:    listed.Imports,
+
+		EmbedPatterns: listed.EmbedPatterns,
+		FileImports:   map[string][]string{},
+	}
+
+	for _, file := range append(append([]string{}, listed.GoFiles...), listed.CgoFiles...) {
+		if imports, err := fileImports(filepath.Join(dir, file)); err == nil {
+			pkg.FileImports[filepath.Join(dir, file)] = imports
 		}
+	}
 
-		if info.IsDir() {
-			return nil
+	for _, files := range [][]string{
+		listed.GoFiles, listed.CgoFiles, listed.CFiles, listed.CXXFiles, listed.HFiles,
+		listed.SFiles, listed.SysoFiles, listed.EmbedFiles, listed.OtherFiles,
+	} {
+		for _, file := range files {
+			file = filepath.Join(dir, file)
+			pkg.Files = append(pkg.Files, file)
+			g.Files[file] = pkg.ImportPath
 		}
-		files = append(files, path)
-		return nil
-	}); err != nil {
-		return nil
 	}
 
-	return files
+	g.Packages[pkg.ImportPath] = pkg
+}
+
+// relative returns path relative to the working directory of gomon when it is
+// possible, the events of the watcher use such paths.
+func relative(wd, path string) string {
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		return path
+	}
+
+	return rel
 }
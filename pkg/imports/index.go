@@ -0,0 +1,249 @@
+package imports
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/expectedsh/gomon/pkg/utils"
+)
+
+// Index is the reverse dependency index shared by the applications: it
+// associates every file to its package and to the applications built with it,
+// so a change is dispatched without going through every graph.
+type Index struct {
+	mutex  sync.RWMutex
+	graphs map[string]*Graph
+	// files associates a file to the applications importing it, with the
+	// import path of its package.
+	files map[string]map[string]string
+	// dirs associates the directory of a package to the applications
+	// importing it, with its import path.
+	dirs map[string]map[string]string
+	// embeds is the subset of dirs of the packages with //go:embed directives,
+	// they can only embed the files under their directory.
+	embeds map[string]map[string]string
+}
+
+func NewIndex() *Index {
+	return &Index{
+		graphs: map[string]*Graph{},
+		files:  map[string]map[string]string{},
+		dirs:   map[string]map[string]string{},
+		embeds: map[string]map[string]string{},
+	}
+}
+
+// Set replaces the graph of the application.
+func (i *Index) Set(app string, graph *Graph) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.remove(app)
+
+	i.graphs[app] = graph
+	for file, importPath := range graph.Files {
+		add(i.files, file, app, importPath)
+	}
+
+	for _, pkg := range graph.Packages {
+		add(i.dirs, pkg.Dir, app, pkg.ImportPath)
+		if len(pkg.EmbedPatterns) > 0 {
+			add(i.embeds, pkg.Dir, app, pkg.ImportPath)
+		}
+	}
+}
+
+func add(m map[string]map[string]string, key, app, importPath string) {
+	if m[key] == nil {
+		m[key] = map[string]string{}
+	}
+	m[key][app] = importPath
+}
+
+func del(m map[string]map[string]string, key, app string) {
+	delete(m[key], app)
+	if len(m[key]) == 0 {
+		delete(m, key)
+	}
+}
+
+// Remove forgets the graph of the application.
+func (i *Index) Remove(app string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	i.remove(app)
+}
+
+func (i *Index) remove(app string) {
+	graph, ok := i.graphs[app]
+	if !ok {
+		return
+	}
+
+	for file := range graph.Files {
+		del(i.files, file, app)
+	}
+
+	for _, pkg := range graph.Packages {
+		del(i.dirs, pkg.Dir, app)
+		del(i.embeds, pkg.Dir, app)
+	}
+
+	delete(i.graphs, app)
+}
+
+// RemoveFile forgets a file that was deleted or renamed.
+func (i *Index) RemoveFile(file string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+
+	for app, importPath := range i.files[file] {
+		graph := i.graphs[app]
+		delete(graph.Files, file)
+
+		if pkg, ok := graph.Packages[importPath]; ok {
+			pkg.Files = without(pkg.Files, file)
+		}
+	}
+	delete(i.files, file)
+}
+
+func without(files []string, file string) []string {
+	kept := make([]string, 0, len(files))
+	for _, f := range files {
+		if f != file {
+			kept = append(kept, f)
+		}
+	}
+
+	return kept
+}
+
+// Graph returns the graph of the application.
+func (i *Index) Graph(app string) (*Graph, bool) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	graph, ok := i.graphs[app]
+	return graph, ok
+}
+
+// Apps returns the sorted names of the applications built with the file.
+func (i *Index) Apps(file string) []string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	apps := make([]string, 0, len(i.files[file]))
+	for app := range i.files[file] {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+
+	return apps
+}
+
+// Candidates returns the sorted names of the applications that can be
+// affected by a change on the file: the ones built with it, with a package in
+// its directory, where a new file is added to the package, or with a package
+// that can embed it.
+func (i *Index) Candidates(file string) []string {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	found := map[string]bool{}
+	for app := range i.files[file] {
+		found[app] = true
+	}
+
+	for app := range i.dirs[filepath.Dir(file)] {
+		found[app] = true
+	}
+
+	for _, dir := range utils.Ancestors(file) {
+		for app := range i.embeds[dir] {
+			found[app] = true
+		}
+	}
+
+	apps := make([]string, 0, len(found))
+	for app := range found {
+		apps = append(apps, app)
+	}
+	sort.Strings(apps)
+
+	return apps
+}
+
+// Package returns the import path of the package of the file in the graph of
+// the application.
+func (i *Index) Package(app, file string) (string, bool) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	importPath, ok := i.files[file][app]
+	return importPath, ok
+}
+
+// ImportsChanged reports whether the imports of a go file of the application
+// changed since its package was listed.
+func (i *Index) ImportsChanged(app, file string) bool {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	graph, ok := i.graphs[app]
+	if !ok {
+		return false
+	}
+
+	return graph.ImportsChanged(file)
+}
+
+// PackageInDir returns the import path of the package of the application in
+// the directory.
+func (i *Index) PackageInDir(app, dir string) (string, bool) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	importPath, ok := i.dirs[filepath.Clean(dir)][app]
+	return importPath, ok
+}
+
+// Embeds reports whether the file matches a //go:embed directive of one of the
+// packages of the application.
+func (i *Index) Embeds(app, file string) bool {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	graph, ok := i.graphs[app]
+	if !ok {
+		return false
+	}
+
+	for _, dir := range utils.Ancestors(file) {
+		importPath, ok := i.embeds[dir][app]
+		if !ok {
+			continue
+		}
+
+		if pkg, ok := graph.Packages[importPath]; ok && pkg.Embeds(file) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Packages returns the packages of the graph of the application.
+func (i *Index) Packages(app string) []*Package {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+
+	graph, ok := i.graphs[app]
+	if !ok {
+		return nil
+	}
+
+	return graph.SortedPackages()
+}
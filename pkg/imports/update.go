@@ -0,0 +1,160 @@
+package imports
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+)
+
+// ImportsChanged reports whether the imports of a go file of the graph are not
+// the ones it had when its package was listed.
+func (g *Graph) ImportsChanged(file string) bool {
+	pkg, ok := g.Packages[g.Files[file]]
+	if !ok {
+		return false
+	}
+
+	imports, err := fileImports(file)
+	if err != nil {
+		// the file is being written, its imports are read on the next change
+		return false
+	}
+
+	return !reflect.DeepEqual(imports, pkg.FileImports[file])
+}
+
+// PackageInDir returns the package of the graph in the directory, where a new
+// file is added to the package.
+func (g *Graph) PackageInDir(dir string) (*Package, bool) {
+	dir = filepath.Clean(dir)
+	for _, pkg := range g.Packages {
+		if pkg.Dir == dir {
+			return pkg, true
+		}
+	}
+
+	return nil, false
+}
+
+// Update lists again a package of the graph with its dependencies and returns
+// the updated graph, the packages that are no longer imported are dropped.
+// The whole graph is listed again when the package is the main package, path
+// is the path of the main package.
+func (g *Graph) Update(path, importPath string, options Options) (*Graph, error) {
+	if importPath == g.Main {
+		return Load(path, options)
+	}
+
+	listed, err := list(importPath, options)
+	if err != nil {
+		return nil, err
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+
+	updated := &Graph{
+		Main:     g.Main,
+		Packages: map[string]*Package{},
+		Files:    map[string]string{},
+		Modules:  map[string]string{},
+	}
+
+	for path, version := range g.Modules {
+		updated.Modules[path] = version
+	}
+
+	for _, pkg := range listed {
+		if pkg.Module != nil {
+			updated.Modules[pkg.Module.Path] = pkg.Module.version()
+		}
+
+		if pkg.Standard || !pkg.Module.isLocal() {
+			continue
+		}
+
+		updated.add(wd, pkg)
+	}
+
+	for _, pkg := range g.Packages {
+		if _, ok := updated.Packages[pkg.ImportPath]; !ok {
+			updated.Packages[pkg.ImportPath] = pkg
+		}
+	}
+
+	updated.prune()
+
+	return updated, nil
+}
+
+// SameDependencies reports whether the graphs are built from the same
+// packages and the same versions of the modules.
+func (g *Graph) SameDependencies(other *Graph) bool {
+	if g.Main != other.Main || len(g.Packages) != len(other.Packages) {
+		return false
+	}
+
+	for importPath := range g.Packages {
+		if _, ok := other.Packages[importPath]; !ok {
+			return false
+		}
+	}
+
+	return reflect.DeepEqual(g.Modules, other.Modules)
+}
+
+// prune drops the packages that can not be reached from the main package and
+// indexes the files of the others.
+func (g *Graph) prune() {
+	reachable := map[string]bool{}
+
+	queue := []string{g.Main}
+	for len(queue) > 0 {
+		importPath := queue[0]
+		queue = queue[1:]
+
+		pkg, ok := g.Packages[importPath]
+		if !ok || reachable[importPath] {
+			continue
+		}
+		reachable[importPath] = true
+
+		queue = append(queue, pkg.Imports...)
+	}
+
+	g.Files = map[string]string{}
+	for importPath, pkg := range g.Packages {
+		if !reachable[importPath] {
+			delete(g.Packages, importPath)
+			continue
+		}
+
+		for _, file := range pkg.Files {
+			g.Files[file] = importPath
+		}
+	}
+}
+
+// fileImports returns the sorted import paths of a go file.
+func fileImports(file string) ([]string, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ImportsOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	imports := make([]string, 0, len(f.Imports))
+	for _, spec := range f.Imports {
+		if importPath, err := strconv.Unquote(spec.Path.Value); err == nil {
+			imports = append(imports, importPath)
+		}
+	}
+	sort.Strings(imports)
+
+	return imports, nil
+}
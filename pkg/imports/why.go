@@ -0,0 +1,76 @@
+package imports
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Resolve returns the import path of the package of the graph described by
+// target: a file of the graph, a file embedded by one of its packages, an
+// import path or the directory of a package.
+func (g *Graph) Resolve(target string) (string, bool) {
+	if _, ok := g.Packages[target]; ok {
+		return target, true
+	}
+
+	file := filepath.Clean(target)
+	if filepath.IsAbs(file) {
+		if wd, err := os.Getwd(); err == nil {
+			file = relative(wd, file)
+		}
+	}
+
+	if importPath, ok := g.Files[file]; ok {
+		return importPath, true
+	}
+
+	if pkg, ok := g.PackageInDir(file); ok {
+		return pkg.ImportPath, true
+	}
+
+	if pkg, ok := g.EmbeddingPackage(file); ok {
+		return pkg.ImportPath, true
+	}
+
+	return "", false
+}
+
+// Chain returns the shortest import chain from the main package to the
+// package, both included.
+func (g *Graph) Chain(importPath string) []string {
+	previous := map[string]string{g.Main: ""}
+
+	queue := []string{g.Main}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if current == importPath {
+			var chain []string
+			for ; current != ""; current = previous[current] {
+				chain = append([]string{current}, chain...)
+			}
+
+			return chain
+		}
+
+		pkg, ok := g.Packages[current]
+		if !ok {
+			continue
+		}
+
+		for _, imported := range pkg.Imports {
+			if _, ok := previous[imported]; ok {
+				continue
+			}
+			if _, ok := g.Packages[imported]; !ok {
+				continue
+			}
+
+			previous[imported] = current
+			queue = append(queue, imported)
+		}
+	}
+
+	return nil
+}
@@ -25,6 +25,13 @@ func Add(name string, cmd *exec.Cmd) {
 	}
 }
 
+func Remove(name string) {
+	pidListMutex.Lock()
+	defer pidListMutex.Unlock()
+
+	delete(pidMap, name)
+}
+
 func Save(hash string) error {
 	pidListMutex.Lock()
 	defer pidListMutex.Unlock()
@@ -3,6 +3,7 @@ package utils
 import (
 	"io/ioutil"
 	"path"
+	"path/filepath"
 )
 
 func GetSubDirectories(dir string, directories *[]string) {
@@ -19,3 +20,16 @@ func GetSubDirectories(dir string, directories *[]string) {
 		}
 	}
 }
+
+// Ancestors returns the directories containing the path, from its own
+// directory up to the root of the relative path or of the file system.
+func Ancestors(p string) []string {
+	var dirs []string
+
+	for dir := filepath.Dir(p); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if dir == "." || dir == string(filepath.Separator) || filepath.Dir(dir) == dir {
+			return dirs
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package utils
+
+import "testing"
+
+func TestAncestors(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{path: "a/b/c.go", want: []string{"a/b", "a", "."}},
+		{path: "c.go", want: []string{"."}},
+		{path: "/a/b", want: []string{"/a", "/"}},
+		{path: "../shared/x.go", want: []string{"../shared", "..", "."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got := Ancestors(tt.path)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Ancestors(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("Ancestors(%q) = %q, want %q", tt.path, got, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -1,13 +1,8 @@
 package utils
 
 import (
-	"crypto/md5"
-	"encoding/hex"
-	"io/ioutil"
 	"os"
 	"path"
-
-	"github.com/ghodss/yaml"
 )
 
 func GetGomonBuilds(hash string) string {
@@ -34,33 +29,3 @@ func GetGomonPidListFile(hash string) string {
 
 	return file
 }
-
-func InitConfig(file string, cfgHash *string, appList interface{}) error {
-	bytes, err := ioutil.ReadFile(file)
-	if err != nil {
-		return err
-	}
-
-	hasher := md5.New()
-	hasher.Write(bytes)
-	*cfgHash = hex.EncodeToString(hasher.Sum(nil))
-
-	if err := yaml.Unmarshal(bytes, appList); err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func InitConfigHash(file string, cfgHash *string) error {
-	bytes, err := ioutil.ReadFile(file)
-	if err != nil {
-		return err
-	}
-
-	hasher := md5.New()
-	hasher.Write(bytes)
-	*cfgHash = hex.EncodeToString(hasher.Sum(nil))
-
-	return nil
-}
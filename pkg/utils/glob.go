@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// MatchGlob reports whether name matches the shell pattern. In addition to the
+// syntax of filepath.Match, a "**" segment matches any number of directories.
+func MatchGlob(pattern, name string) bool {
+	return matchSegments(splitPath(pattern), splitPath(name))
+}
+
+// MatchGlobDir reports whether name is a directory matching the pattern or is
+// located somewhere under such a directory.
+func MatchGlobDir(pattern, name string) bool {
+	patternSegments := splitPath(pattern)
+	nameSegments := splitPath(name)
+
+	for i := 1; i <= len(nameSegments); i++ {
+		if matchSegments(patternSegments, nameSegments[:i]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GlobBase returns the longest leading directory of the pattern that does not
+// contain any meta character, it is the directory to walk to find matches.
+func GlobBase(pattern string) string {
+	var base []string
+
+	for _, segment := range splitPath(pattern) {
+		if strings.ContainsAny(segment, "*?[\\") {
+			break
+		}
+		base = append(base, segment)
+	}
+
+	if len(base) == 0 {
+		return "."
+	}
+
+	return strings.Join(base, "/")
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+
+		if ok, err := filepath.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0
+}
+
+func splitPath(p string) []string {
+	p = filepath.ToSlash(filepath.Clean(p))
+	if p == "." {
+		return nil
+	}
+
+	return strings.Split(p, "/")
+}
@@ -0,0 +1,101 @@
+package utils
+
+import "testing"
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "*.go", name: "main.go", want: true},
+		{pattern: "*.go", name: "cmd/main.go", want: false},
+		{pattern: "cmd/*.go", name: "cmd/main.go", want: true},
+		{pattern: "cmd/*/main.go", name: "cmd/api/main.go", want: true},
+		{pattern: "cmd/*/main.go", name: "cmd/api/v2/main.go", want: false},
+
+		// ** matches any number of directories, none included
+		{pattern: "**/*.go", name: "main.go", want: true},
+		{pattern: "**/*.go", name: "pkg/a/b/c.go", want: true},
+		{pattern: "**/*_gen.go", name: "pkg/a/b/c.go", want: false},
+		{pattern: "templates/**/*.tmpl", name: "templates/a.tmpl", want: true},
+		{pattern: "templates/**/*.tmpl", name: "templates/a/b/c.tmpl", want: true},
+		{pattern: "templates/**/*.tmpl", name: "other/templates/a.tmpl", want: false},
+		{pattern: "**/mocks/**", name: "pkg/mocks/store.go", want: true},
+		{pattern: "**/mocks/**", name: "pkg/mocks", want: true},
+		{pattern: "**", name: "any/path/at/all", want: true},
+
+		// the paths are cleaned
+		{pattern: "./cmd/*.go", name: "cmd/main.go", want: true},
+		{pattern: "cmd/*.go", name: "./cmd/main.go", want: true},
+		{pattern: "cmd/../pkg/*.go", name: "pkg/a.go", want: true},
+		{pattern: "pkg/", name: "pkg", want: true},
+		{pattern: "pkg", name: "pkg/", want: true},
+
+		{pattern: "[", name: "[", want: false},
+		{pattern: "file?.go", name: "file1.go", want: true},
+		{pattern: "file[0-9].go", name: "filea.go", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := MatchGlob(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("MatchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchGlobDir(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "config", name: "config", want: true},
+		{pattern: "config", name: "config/app.yaml", want: true},
+		{pattern: "config", name: "config/a/b.yaml", want: true},
+		{pattern: "config", name: "configs/app.yaml", want: false},
+		{pattern: "config/", name: "config/app.yaml", want: true},
+		{pattern: "./config", name: "config/app.yaml", want: true},
+		{pattern: "**/testdata", name: "pkg/a/testdata/file.txt", want: true},
+		{pattern: "**/testdata", name: "testdata/file.txt", want: true},
+		{pattern: "**/testdata", name: "pkg/testdatas/file.txt", want: false},
+		{pattern: "pkg/*/internal", name: "pkg/a/internal/x.go", want: true},
+		{pattern: "pkg/*/internal", name: "pkg/internal/x.go", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if got := MatchGlobDir(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("MatchGlobDir(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGlobBase(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string
+	}{
+		{pattern: "templates/**/*.tmpl", want: "templates"},
+		{pattern: "web/static/*.js", want: "web/static"},
+		{pattern: "**/*.go", want: "."},
+		{pattern: "*.go", want: "."},
+		{pattern: "config", want: "config"},
+		{pattern: "config/", want: "config"},
+		{pattern: "./config/app.yaml", want: "config/app.yaml"},
+		{pattern: "a/b?/c", want: "a"},
+		{pattern: "a/[bc]/d", want: "a"},
+		{pattern: ".", want: "."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			if got := GlobBase(tt.pattern); got != tt.want {
+				t.Errorf("GlobBase(%q) = %q, want %q", tt.pattern, got, tt.want)
+			}
+		})
+	}
+}